@@ -2,9 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/jamesainslie/grimoire/internal/embed"
 	"github.com/jamesainslie/grimoire/internal/store"
@@ -13,21 +17,106 @@ import (
 
 // Global configuration
 var (
-	dbPath    string
-	ollamaURL string
+	dbPath  string
+	encoder *embed.Encoder
+	ranking store.Ranking
 )
 
 // Tool argument types
 type queryArgs struct {
-	Query    string `json:"query" jsonschema_description:"The search query"`
-	Language string `json:"language,omitempty" jsonschema_description:"Filter results by programming language (e.g. go, rust)"`
-	Limit    int    `json:"limit,omitempty" jsonschema_description:"Maximum number of results (default 5, max 20)"`
+	Query    string `json:"query" jsonschema:"The search query"`
+	Language string `json:"language,omitempty" jsonschema:"Filter results by programming language (e.g. go, rust)"`
+	Limit    int    `json:"limit,omitempty" jsonschema:"Maximum number of results (default 5, max 20; with max_tokens, candidates to pack, default and max 50)"`
+
+	Sources        []string `json:"sources,omitempty" jsonschema:"Only search these sources, by name (see list_sources)"`
+	ExcludeSources []string `json:"exclude_sources,omitempty" jsonschema:"Skip these sources, by name"`
+	Tiers          []int    `json:"tiers,omitempty" jsonschema:"Only search sources of these tiers (1 = official documentation)"`
+	Levels         []string `json:"levels,omitempty" jsonschema:"Only return chunks of these levels: summary, section, paragraph"`
+	Path           string   `json:"path,omitempty" jsonschema:"Only search documents whose path starts with this prefix"`
+	CodeOnly       bool     `json:"code_only,omitempty" jsonschema:"Only return chunks containing code examples"`
+	Advanced       bool     `json:"advanced,omitempty" jsonschema:"Treat query as raw SQLite FTS5 syntax (AND, OR, NOT, NEAR, prefix*) for the full-text part of the search"`
+	Diversity      float64  `json:"diversity,omitempty" jsonschema:"Trade relevance for variety, from 0 (default) to 1; higher values demote results similar to ones already returned"`
+	MaxPerDocument int      `json:"max_per_document,omitempty" jsonschema:"Maximum results from one document (default no limit)"`
+	Expand         bool     `json:"expand,omitempty" jsonschema:"Widen each result with neighbouring chunks of its section and merge adjacent results into one passage"`
+	Window         int      `json:"window,omitempty" jsonschema:"Neighbouring chunks on each side of a result to include when expanding (default 1)"`
+	MaxTokens      int      `json:"max_tokens,omitempty" jsonschema:"Token budget for the response: pack the best-ranked distinct passages until it is reached, trimming the last, instead of returning a fixed number of results"`
+}
+
+// queryOutput is the structured result of the query tool.
+type queryOutput struct {
+	Results        []queryResult `json:"results" jsonschema:"Matching passages, most relevant first"`
+	Query          string        `json:"query" jsonschema:"The search query"`
+	LanguageFilter string        `json:"language_filter,omitempty" jsonschema:"Language the results were filtered by, if any"`
+}
+
+// queryResult is one search result in a queryOutput.
+type queryResult struct {
+	Content   string  `json:"content" jsonschema:"Text of the result"`
+	Language  string  `json:"language" jsonschema:"Programming language of the source"`
+	Source    string  `json:"source" jsonschema:"Name of the source"`
+	Tier      int     `json:"tier,omitempty" jsonschema:"Priority tier of the source (1 = official documentation)"`
+	Section   string  `json:"section" jsonschema:"Section breadcrumbs from the document title down, separated by ' > '"`
+	Path      string  `json:"path" jsonschema:"Document path within the source"`
+	Anchor    string  `json:"anchor,omitempty" jsonschema:"Anchor of the section heading"`
+	Revision  string  `json:"revision,omitempty" jsonschema:"Commit the source was indexed at"`
+	URL       string  `json:"url,omitempty" jsonschema:"Web link to the section"`
+	Relevance float64 `json:"relevance" jsonschema:"Relevance from 0 to 1"`
+	ChunkID   int64   `json:"chunk_id" jsonschema:"ID of the best matching chunk"`
+	Truncated bool    `json:"truncated,omitempty" jsonschema:"Content was trimmed to fit max_tokens"`
+}
+
+type getDocumentArgs struct {
+	Source   string `json:"source" jsonschema:"Name of the source the document belongs to (see list_sources)"`
+	Path     string `json:"path" jsonschema:"Path of the document within the source, as in query results"`
+	Anchor   string `json:"anchor,omitempty" jsonschema:"Only return the section with this heading anchor, and its subsections"`
+	Language string `json:"language,omitempty" jsonschema:"Language of the source, if sources of several languages share its name"`
+}
+
+// documentOutput is the structured result of the get_document tool.
+type documentOutput struct {
+	Title        string          `json:"title" jsonschema:"Title of the document"`
+	Language     string          `json:"language" jsonschema:"Programming language of the source"`
+	Source       string          `json:"source" jsonschema:"Name of the source"`
+	Path         string          `json:"path" jsonschema:"Document path within the source"`
+	URL          string          `json:"url,omitempty" jsonschema:"Web link to the document"`
+	Introduction string          `json:"introduction,omitempty" jsonschema:"Body of a document without headings; omitted when an anchor is given"`
+	Sections     []sectionOutput `json:"sections" jsonschema:"Sections in document order"`
+}
+
+// sectionOutput is one section in a documentOutput.
+type sectionOutput struct {
+	Title   string `json:"title" jsonschema:"Section heading"`
+	Anchor  string `json:"anchor,omitempty" jsonschema:"Heading anchor, for fetching just this section"`
+	Level   int    `json:"level,omitempty" jsonschema:"Heading level, 1 for a top-level heading"`
+	Section string `json:"section" jsonschema:"Section breadcrumbs from the document title down, separated by ' > '"`
+	Content string `json:"content" jsonschema:"Text of the section"`
+	URL     string `json:"url,omitempty" jsonschema:"Web link to the section"`
+}
+
+type listTopicsArgs struct {
+	Language string   `json:"language,omitempty" jsonschema:"Only list topics of this programming language"`
+	Sources  []string `json:"sources,omitempty" jsonschema:"Only list topics of these sources, by name"`
+	Topic    string   `json:"topic,omitempty" jsonschema:"List the topics under this one, given as its path, e.g. 'Effective Go > Errors'"`
+	Depth    int      `json:"depth,omitempty" jsonschema:"Levels of topics to list (default 2)"`
+}
+
+// topicsOutput is the structured result of the list_topics tool.
+type topicsOutput struct {
+	Topics []topicOutput `json:"topics" jsonschema:"Topics in outline order: each topic is followed by its subtopics"`
+}
+
+// topicOutput is one topic in a topicsOutput.
+type topicOutput struct {
+	Topic     string `json:"topic" jsonschema:"Path of the topic from the document title down, separated by ' > '"`
+	Name      string `json:"name" jsonschema:"Name of the topic"`
+	Depth     int    `json:"depth" jsonschema:"Depth below the listed topic, starting at 1"`
+	Documents int    `json:"documents" jsonschema:"Number of documents covering the topic"`
 }
 
 type listLanguagesArgs struct{}
 
 type listSourcesArgs struct {
-	Language string `json:"language,omitempty" jsonschema_description:"Filter sources by programming language"`
+	Language string `json:"language,omitempty" jsonschema:"Filter sources by programming language"`
 }
 
 func main() {
@@ -37,17 +126,131 @@ func main() {
 		dbPath = getDefaultDBPath()
 	}
 
-	ollamaURL = os.Getenv("OLLAMA_URL")
-	if ollamaURL == "" {
-		ollamaURL = "http://localhost:11434"
+	// GRIMOIRE_EMBED_URL takes precedence; OLLAMA_URL is kept for existing configs
+	provider := os.Getenv("GRIMOIRE_EMBED_PROVIDER")
+	embedURL := os.Getenv("GRIMOIRE_EMBED_URL")
+	if embedURL == "" && (provider == "" || provider == embed.ProviderOllama) {
+		embedURL = os.Getenv("OLLAMA_URL")
+	}
+
+	opts, err := embedOptionsFromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	embedder, err := embed.New(embed.Config{
+		Provider: provider,
+		BaseURL:  embedURL,
+		Model:    os.Getenv("GRIMOIRE_EMBED_MODEL"),
+		APIKey:   os.Getenv("GRIMOIRE_EMBED_API_KEY"),
+		Options:  opts,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Cache query embeddings so repeated queries skip the provider
+	var cache *store.EmbeddingCache
+	if os.Getenv("GRIMOIRE_EMBED_CACHE") != "off" {
+		cache, err = openEmbeddingCache()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		embedder = embed.NewCached(embedder, cache)
+	}
+	encoder = embed.NewEncoder(embedder)
+
+	ranking, err = rankingFromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
 	}
 
-	if err := run(); err != nil {
+	err = run()
+	if cache != nil {
+		cache.Close()
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
+// openEmbeddingCache opens the embedding cache shared with the CLI, stored
+// in the cache directory next to the database.
+func openEmbeddingCache() (*store.EmbeddingCache, error) {
+	path := filepath.Join(filepath.Dir(dbPath), "cache", "embeddings.db")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("create cache dir: %w", err)
+	}
+	return store.OpenEmbeddingCache(path)
+}
+
+// embedOptionsFromEnv reads GRIMOIRE_EMBED_TIMEOUT (a Go duration such as
+// "90s") and GRIMOIRE_EMBED_RETRIES. Unset values use the embed defaults.
+func embedOptionsFromEnv() (embed.Options, error) {
+	var opts embed.Options
+	if v := os.Getenv("GRIMOIRE_EMBED_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid GRIMOIRE_EMBED_TIMEOUT: %w", err)
+		}
+		opts.Timeout = d
+	}
+	if v := os.Getenv("GRIMOIRE_EMBED_RETRIES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid GRIMOIRE_EMBED_RETRIES: %w", err)
+		}
+		opts.MaxRetries = &n
+	}
+	return opts, nil
+}
+
+// rankingFromEnv reads hybrid ranking settings from the environment
+// variables shared with the CLI. Unset variables keep the defaults.
+func rankingFromEnv() (store.Ranking, error) {
+	r := store.Ranking{Fusion: os.Getenv("GRIMOIRE_FUSION")}
+
+	weights, err := store.ParseTierWeights(os.Getenv("GRIMOIRE_TIER_WEIGHTS"))
+	if err != nil {
+		return r, fmt.Errorf("invalid GRIMOIRE_TIER_WEIGHTS: %w", err)
+	}
+	r.TierWeights = weights
+
+	if v := os.Getenv("GRIMOIRE_RRF_K"); v != "" {
+		k, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return r, fmt.Errorf("invalid GRIMOIRE_RRF_K: %w", err)
+		}
+		r.RRFK = k
+	}
+	for key, dst := range map[string]**float64{
+		"GRIMOIRE_VECTOR_WEIGHT": &r.VectorWeight,
+		"GRIMOIRE_TEXT_WEIGHT":   &r.TextWeight,
+		"GRIMOIRE_SYMBOL_WEIGHT": &r.SymbolWeight,
+	} {
+		if v := os.Getenv(key); v != "" {
+			w, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return r, fmt.Errorf("invalid %s: %w", key, err)
+			}
+			*dst = &w
+		}
+	}
+	if v := os.Getenv("GRIMOIRE_CANDIDATES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return r, fmt.Errorf("invalid GRIMOIRE_CANDIDATES: %w", err)
+		}
+		r.CandidatePool = n
+	}
+	return r, r.Validate()
+}
+
 func getDefaultDBPath() string {
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -66,11 +269,27 @@ func run() error {
 	// Add query tool
 	mcp.AddTool(server, &mcp.Tool{
 		Name:        "query",
-		Description: "Search the grimoire knowledge base for programming best practices, patterns, and documentation. Returns relevant chunks from curated sources.",
-	}, func(ctx context.Context, req *mcp.CallToolRequest, args queryArgs) (*mcp.CallToolResult, any, error) {
+		Description: "Search the grimoire knowledge base for programming best practices, patterns, and documentation. Returns relevant chunks from curated sources, as Markdown and as structured results.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args queryArgs) (*mcp.CallToolResult, *queryOutput, error) {
 		return handleQuery(ctx, args)
 	})
 
+	// Add get_document tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "get_document",
+		Description: "Retrieve a full document from the grimoire knowledge base by source and path, or one section of it by heading anchor. Use after query to read a result in full.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args getDocumentArgs) (*mcp.CallToolResult, *documentOutput, error) {
+		return handleGetDocument(ctx, args)
+	})
+
+	// Add list_topics tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "list_topics",
+		Description: "List the topics covered by the grimoire knowledge base: document titles and the headings under them, with how many documents cover each. Use it to find good queries, and pass a topic to browse the topics under it.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args listTopicsArgs) (*mcp.CallToolResult, *topicsOutput, error) {
+		return handleListTopics(ctx, args)
+	})
+
 	// Add list_languages tool
 	mcp.AddTool(server, &mcp.Tool{
 		Name:        "list_languages",
@@ -91,16 +310,31 @@ func run() error {
 	return server.Run(context.Background(), &mcp.StdioTransport{})
 }
 
-func handleQuery(ctx context.Context, args queryArgs) (*mcp.CallToolResult, any, error) {
+func handleQuery(ctx context.Context, args queryArgs) (*mcp.CallToolResult, *queryOutput, error) {
 	// Validate and set defaults
 	if args.Query == "" {
 		return nil, nil, fmt.Errorf("query is required")
 	}
+	maxLimit := 20
+	if args.MaxTokens > 0 {
+		// The budget decides how many results fit
+		maxLimit = store.PackCandidates
+		if args.Limit <= 0 {
+			args.Limit = store.PackCandidates
+		}
+	}
 	if args.Limit <= 0 {
 		args.Limit = 5
 	}
-	if args.Limit > 20 {
-		args.Limit = 20
+	if args.Limit > maxLimit {
+		args.Limit = maxLimit
+	}
+
+	// Every reply carries structured results, empty if nothing matched
+	out := &queryOutput{
+		Results:        []queryResult{},
+		Query:          args.Query,
+		LanguageFilter: args.Language,
 	}
 
 	// Open database
@@ -110,8 +344,20 @@ func handleQuery(ctx context.Context, args queryArgs) (*mcp.CallToolResult, any,
 	}
 	defer db.Close()
 
+	opts := store.SearchOptions{
+		Sources:        args.Sources,
+		ExcludeSources: args.ExcludeSources,
+		Tiers:          args.Tiers,
+		Levels:         args.Levels,
+		PathPrefix:     args.Path,
+		CodeOnly:       args.CodeOnly,
+		AdvancedQuery:  args.Advanced,
+		Diversity:      args.Diversity,
+		MaxPerDocument: args.MaxPerDocument,
+		Ranking:        ranking,
+	}
+
 	// Get language ID if specified
-	var languageID int64
 	if args.Language != "" {
 		lang, err := db.GetLanguage(ctx, args.Language)
 		if err != nil {
@@ -119,20 +365,29 @@ func handleQuery(ctx context.Context, args queryArgs) (*mcp.CallToolResult, any,
 				Content: []mcp.Content{
 					&mcp.TextContent{Text: fmt.Sprintf("Language %q not found in knowledge base.", args.Language)},
 				},
-			}, nil, nil
+			}, out, nil
 		}
-		languageID = lang.ID
+		opts.LanguageID = lang.ID
 	}
 
 	// Get query embedding
-	client := embed.New(ollamaURL, "snowflake-arctic-embed:l")
-	queryVec, err := client.Embed(ctx, args.Query)
+	queryVec, err := encoder.EmbedQuery(ctx, args.Query)
 	if err != nil {
 		return nil, nil, fmt.Errorf("get embedding: %w", err)
 	}
+	if err := db.CheckEmbeddingModel(ctx, encoder.Model(), len(queryVec)); err != nil {
+		return nil, nil, err
+	}
 
 	// Perform hybrid search
-	results, err := db.SearchChunksHybrid(ctx, queryVec, args.Query, languageID, args.Limit)
+	results, err := db.SearchChunksHybrid(ctx, queryVec, args.Query, opts, args.Limit)
+	if errors.Is(err, store.ErrNotFound) {
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: fmt.Sprintf("Search failed: %v. Use list_sources to see available sources.", err)},
+			},
+		}, out, nil
+	}
 	if err != nil {
 		return nil, nil, fmt.Errorf("search: %w", err)
 	}
@@ -142,7 +397,40 @@ func handleQuery(ctx context.Context, args queryArgs) (*mcp.CallToolResult, any,
 			Content: []mcp.Content{
 				&mcp.TextContent{Text: "No results found for the query."},
 			},
-		}, nil, nil
+		}, out, nil
+	}
+
+	if args.Expand || args.MaxTokens > 0 {
+		window := 0 // Still merge adjacent hits, which saves repeated headers
+		if args.Expand {
+			window = args.Window
+			if window <= 0 {
+				window = 1
+			}
+		}
+		passages, err := db.ExpandResults(ctx, results, window)
+		if err != nil {
+			return nil, nil, fmt.Errorf("expand results: %w", err)
+		}
+
+		var text string
+		if args.MaxTokens > 0 {
+			packing := store.PackPassages(passages, args.MaxTokens)
+			passages = packing.Passages
+			text = formatPassages(passages)
+			text += fmt.Sprintf("_Packed %d passages in about %d of %d tokens; dropped %d candidates for space and %d duplicates._\n",
+				len(packing.Passages), packing.Tokens, args.MaxTokens, packing.Dropped, packing.Duplicates)
+		} else {
+			text = formatPassages(passages)
+		}
+		for _, p := range passages {
+			out.Results = append(out.Results, passageResult(p))
+		}
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: text},
+			},
+		}, out, nil
 	}
 
 	// Format results
@@ -152,15 +440,209 @@ func handleQuery(ctx context.Context, args queryArgs) (*mcp.CallToolResult, any,
 		relevance := 1.0 - r.Distance
 		text += fmt.Sprintf("## Result %d (relevance: %.0f%%)\n", i+1, relevance*100)
 		text += fmt.Sprintf("**Title:** %s\n", r.Chunk.Title)
+		text += fmt.Sprintf("**Source:** %s\n", r.Source.String())
+		text += fmt.Sprintf("**Document:** %s\n", r.Citation.String())
+		if r.Citation.URL != "" {
+			text += fmt.Sprintf("**URL:** %s\n", r.Citation.URL)
+		}
 		text += fmt.Sprintf("**Level:** %s\n\n", r.Chunk.Level)
 		text += r.Chunk.Content + "\n\n---\n\n"
+		out.Results = append(out.Results, searchResult(r))
 	}
 
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{
 			&mcp.TextContent{Text: text},
 		},
-	}, nil, nil
+	}, out, nil
+}
+
+// formatPassages formats expanded search results as Markdown.
+func formatPassages(passages []*store.Passage) string {
+	var text string
+	for i, p := range passages {
+		relevance := 1.0 - p.Distance
+		text += fmt.Sprintf("## Result %d (relevance: %.0f%%)\n", i+1, relevance*100)
+		text += fmt.Sprintf("**Section:** %s\n", strings.Join(p.Breadcrumbs, " > "))
+		text += fmt.Sprintf("**Source:** %s\n", p.Source.String())
+		text += fmt.Sprintf("**Document:** %s\n", p.Citation.String())
+		if p.Citation.URL != "" {
+			text += fmt.Sprintf("**URL:** %s\n", p.Citation.URL)
+		}
+		text += "\n"
+		text += p.Content() + "\n\n"
+		if p.Truncated {
+			text += "_(trimmed to fit the token budget)_\n\n"
+		}
+		text += "---\n\n"
+	}
+	return text
+}
+
+// searchResult converts a search result to its structured form.
+func searchResult(r *store.SearchResult) queryResult {
+	// Chunks indexed before breadcrumbs were recorded only have a title
+	crumbs := r.Chunk.Breadcrumbs
+	if len(crumbs) == 0 {
+		crumbs = []string{r.Chunk.Title}
+	}
+	res := citedResult(r.Citation, r.Chunk.Content, crumbs, r.Distance)
+	res.ChunkID = r.Chunk.ID
+	return res
+}
+
+// passageResult converts an expanded search result to its structured form.
+func passageResult(p *store.Passage) queryResult {
+	res := citedResult(p.Citation, p.Content(), p.Breadcrumbs, p.Distance)
+	res.ChunkID = p.Hits[0]
+	res.Truncated = p.Truncated
+	return res
+}
+
+// citedResult returns a structured result with content, section and
+// citation filled in.
+func citedResult(c *store.Citation, content string, breadcrumbs []string, distance float64) queryResult {
+	return queryResult{
+		Content:   content,
+		Language:  c.Language,
+		Source:    c.Source,
+		Tier:      c.Tier,
+		Section:   strings.Join(breadcrumbs, " > "),
+		Path:      c.Path,
+		Anchor:    c.Anchor,
+		Revision:  c.Revision,
+		URL:       c.URL,
+		Relevance: 1.0 - distance,
+	}
+}
+
+func handleGetDocument(ctx context.Context, args getDocumentArgs) (*mcp.CallToolResult, *documentOutput, error) {
+	if args.Source == "" || args.Path == "" {
+		return nil, nil, fmt.Errorf("source and path are required")
+	}
+
+	db, err := store.New(dbPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open database: %w", err)
+	}
+	defer db.Close()
+
+	doc, err := db.GetDocument(ctx, store.DocumentRef{Language: args.Language, Source: args.Source, Path: args.Path})
+	if err != nil {
+		return nil, nil, fmt.Errorf("get document: %w", err)
+	}
+
+	out := &documentOutput{
+		Title:    doc.Document.Title,
+		Language: doc.Language,
+		Source:   doc.Source.Name,
+		Path:     doc.Document.Path,
+		URL:      doc.URL,
+		Sections: []sectionOutput{},
+	}
+	sections := doc.Sections
+	if args.Anchor != "" {
+		if sections = doc.Section(args.Anchor); sections == nil {
+			return nil, nil, fmt.Errorf("section %q not found in %s/%s", args.Anchor, args.Source, args.Path)
+		}
+	} else {
+		out.Introduction = doc.Introduction
+	}
+
+	text := fmt.Sprintf("# %s\n\n", doc.Document.Title)
+	text += fmt.Sprintf("**Source:** %s\n", doc.Source.String())
+	text += fmt.Sprintf("**Language:** %s\n", doc.Language)
+	if doc.URL != "" {
+		text += fmt.Sprintf("**URL:** %s\n", doc.URL)
+	}
+	if out.Introduction != "" {
+		text += "\n" + out.Introduction + "\n"
+	}
+	for _, sec := range sections {
+		level := sec.Level
+		if level == 0 {
+			level = 2 // Indexed before heading levels were recorded
+		}
+		text += fmt.Sprintf("\n%s %s\n", strings.Repeat("#", level), sec.Title)
+		if sec.Content != "" {
+			text += "\n" + sec.Content + "\n"
+		}
+
+		out.Sections = append(out.Sections, sectionOutput{
+			Title:   sec.Title,
+			Anchor:  sec.Anchor,
+			Level:   sec.Level,
+			Section: strings.Join(sec.Breadcrumbs, " > "),
+			Content: sec.Content,
+			URL:     sec.URL,
+		})
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: text},
+		},
+	}, out, nil
+}
+
+func handleListTopics(ctx context.Context, args listTopicsArgs) (*mcp.CallToolResult, *topicsOutput, error) {
+	if args.Depth <= 0 {
+		args.Depth = 2
+	}
+
+	db, err := store.New(dbPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open database: %w", err)
+	}
+	defer db.Close()
+
+	opts := store.TopicOptions{Sources: args.Sources, Depth: args.Depth}
+	if args.Topic != "" {
+		for _, name := range strings.Split(args.Topic, ">") {
+			opts.Parent = append(opts.Parent, strings.TrimSpace(name))
+		}
+	}
+	if args.Language != "" {
+		lang, err := db.GetLanguage(ctx, args.Language)
+		if err != nil {
+			return nil, nil, fmt.Errorf("language %q not found", args.Language)
+		}
+		opts.LanguageID = lang.ID
+	}
+
+	topics, err := db.ListTopics(ctx, opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("list topics: %w", err)
+	}
+
+	out := &topicsOutput{Topics: []topicOutput{}}
+	var text string
+	var walk func(topics []*store.Topic, depth int)
+	walk = func(topics []*store.Topic, depth int) {
+		for _, t := range topics {
+			text += fmt.Sprintf("%s- **%s** (%d)\n", strings.Repeat("  ", depth-1), t.Name, t.Documents)
+			out.Topics = append(out.Topics, topicOutput{
+				Topic:     strings.Join(t.Path, " > "),
+				Name:      t.Name,
+				Depth:     depth,
+				Documents: t.Documents,
+			})
+			walk(t.Children, depth+1)
+		}
+	}
+	walk(topics, 1)
+
+	if len(topics) == 0 {
+		text = "No topics found."
+	} else {
+		text = "Topics, with the number of documents covering each:\n\n" + text
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: text},
+		},
+	}, out, nil
 }
 
 func handleListLanguages(ctx context.Context) (*mcp.CallToolResult, any, error) {
@@ -236,7 +718,11 @@ func handleListSources(ctx context.Context, args listSourcesArgs) (*mcp.CallTool
 		text += "All sources:\n\n"
 	}
 	for _, src := range sources {
-		text += fmt.Sprintf("- **%s** (%s): %s\n", src.Name, src.Type, src.URL)
+		text += fmt.Sprintf("- **%s** (%s", src.Name, src.Type)
+		if src.Tier != 0 {
+			text += fmt.Sprintf(", tier %d", src.Tier)
+		}
+		text += fmt.Sprintf("): %s\n", src.URL)
 	}
 
 	return &mcp.CallToolResult{
@@ -1,16 +1,19 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jamesainslie/grimoire/internal/chunk"
 	"github.com/jamesainslie/grimoire/internal/embed"
 	"github.com/jamesainslie/grimoire/internal/ingest"
-	"github.com/jamesainslie/grimoire/internal/parse"
 	"github.com/jamesainslie/grimoire/internal/source/git"
 	"github.com/jamesainslie/grimoire/internal/store"
 	"github.com/spf13/cobra"
@@ -18,8 +21,14 @@ import (
 
 // Global flags
 var (
-	dbPath    string
-	ollamaURL string
+	dbPath        string
+	ollamaURL     string
+	embedProvider string
+	embedURL      string
+	embedModel    string
+	embedTimeout  time.Duration
+	embedRetries  int
+	noEmbedCache  bool
 )
 
 func main() {
@@ -63,6 +72,44 @@ var languagesInstallCmd = &cobra.Command{
 	},
 }
 
+var languagesRemoveCmd = &cobra.Command{
+	Use:   "remove <language>",
+	Short: "Remove a language and all of its sources from the knowledge base",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		db, err := store.New(getDBPath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer db.Close()
+
+		lang, err := db.GetLanguage(ctx, args[0])
+		if err != nil {
+			return fmt.Errorf("language %q not found: %w", args[0], err)
+		}
+
+		sources, err := db.ListSources(ctx, lang.ID)
+		if err != nil {
+			return fmt.Errorf("list sources: %w", err)
+		}
+
+		prompt := fmt.Sprintf("Remove language %s and its %d sources, including all documents, chunks and embeddings?", lang.Name, len(sources))
+		if !yes && !confirm(cmd, prompt) {
+			fmt.Println("Aborted.")
+			return nil
+		}
+
+		if err := db.DeleteLanguage(ctx, lang.ID); err != nil {
+			return fmt.Errorf("remove language: %w", err)
+		}
+		fmt.Printf("Removed language %s.\n", lang.Name)
+		return nil
+	},
+}
+
 // Ingest command
 var ingestCmd = &cobra.Command{
 	Use:   "ingest <language-pack-path>",
@@ -72,6 +119,9 @@ var ingestCmd = &cobra.Command{
 		ctx := context.Background()
 		packPath := args[0]
 		sourceFilter, _ := cmd.Flags().GetString("source")
+		noPrune, _ := cmd.Flags().GetBool("no-prune")
+		batchSize, _ := cmd.Flags().GetInt("batch-size")
+		concurrency, _ := cmd.Flags().GetInt("concurrency")
 
 		// Load language pack
 		fmt.Printf("Loading language pack from %s...\n", packPath)
@@ -108,10 +158,23 @@ var ingestCmd = &cobra.Command{
 			return fmt.Errorf("create cache dir: %w", err)
 		}
 		fetcher := git.NewFetcher(cacheDir)
-		embedClient := embed.New(ollamaURL, "snowflake-arctic-embed:l")
+		embedClient, closeEmbedder, err := newEmbedder(embedModel)
+		if err != nil {
+			return fmt.Errorf("create embedder: %w", err)
+		}
+		defer closeEmbedder()
+		// Fail fast rather than embedding every file with the wrong model
+		if model, err := db.EmbeddingModel(ctx); err == nil && model.Name != embedClient.Model() {
+			return fmt.Errorf("database was built with %s but --embed-model is %s: %w", model.Name, embedClient.Model(), store.ErrModelMismatch)
+		}
 		chunker := chunk.NewChunker(512) // ~512 tokens per chunk
+		ingester := ingest.NewIngester(db, embedClient, chunker, ingest.Options{
+			BatchSize:   batchSize,
+			Concurrency: concurrency,
+		})
 
 		// Process each source
+		var report ingest.Report
 		for _, srcDef := range pack.Sources {
 			// Filter by source if specified
 			if sourceFilter != "" && srcDef.Name != sourceFilter {
@@ -135,21 +198,19 @@ var ingestCmd = &cobra.Command{
 			}
 
 			// Create or get source
-			src, err := db.CreateSource(ctx, lang.ID, srcDef.Name, srcDef.Type, srcDef.URL)
+			src, err := db.GetSource(ctx, lang.ID, srcDef.Name)
 			if err != nil {
-				// Source might already exist, try to find it
-				sources, _ := db.ListSources(ctx, lang.ID)
-				for _, s := range sources {
-					if s.Name == srcDef.Name {
-						src = s
-						break
-					}
-				}
-				if src == nil {
+				src, err = db.CreateSource(ctx, lang.ID, srcDef.Name, srcDef.Type, srcDef.URL)
+				if err != nil {
 					fmt.Printf("  Error creating source: %v\n", err)
 					continue
 				}
 			}
+			if src.Tier != srcDef.Tier {
+				if err := db.SetSourceTier(ctx, src.ID, srcDef.Tier); err != nil {
+					fmt.Printf("  Error setting tier: %v\n", err)
+				}
+			}
 
 			// List files matching patterns
 			files, err := fetcher.ListFiles(repoPath, srcDef.Paths)
@@ -159,80 +220,40 @@ var ingestCmd = &cobra.Command{
 			}
 			fmt.Printf("  Found %d files\n", len(files))
 
-			// Process each file
-			for _, relPath := range files {
-				fullPath := filepath.Join(repoPath, relPath)
-				fmt.Printf("    Processing: %s\n", relPath)
-
-				// Read and parse file
-				content, err := os.ReadFile(fullPath)
-				if err != nil {
-					fmt.Printf("      Error reading: %v\n", err)
-					continue
-				}
-
-				doc, err := parse.Parse(content)
-				if err != nil {
-					fmt.Printf("      Error parsing: %v\n", err)
-					continue
-				}
-
-				// Create or get document
-				dbDoc, err := db.CreateDocument(ctx, src.ID, relPath, doc.Title)
-				if err != nil {
-					// Document might already exist, try to find it
-					dbDoc, err = db.GetDocumentByPath(ctx, src.ID, relPath)
-					if err != nil {
-						fmt.Printf("      Error creating document: %v\n", err)
-						continue
-					}
-					fmt.Printf("      (already indexed, skipping)\n")
-					continue
+			// Process files, embedding chunks in concurrent batches
+			srcReport := ingester.IngestFiles(ctx, src.ID, repoPath, files, func(e ingest.FileEvent) {
+				switch {
+				case e.Err != nil:
+					fmt.Printf("    %s: error: %v\n", e.Path, e.Err)
+				case e.Result.Oversize > 0:
+					fmt.Printf("    %s: %s (%d chunks, %d oversize)\n", e.Path, e.Result.Outcome, e.Result.Chunks, e.Result.Oversize)
+				case e.Result.Outcome != ingest.Unchanged:
+					fmt.Printf("    %s: %s (%d chunks)\n", e.Path, e.Result.Outcome, e.Result.Chunks)
 				}
+			})
 
-				// Chunk the document
-				chunks, err := chunker.Chunk(doc)
+			// Remove documents that no longer exist upstream
+			if !noPrune {
+				removed, err := ingester.Prune(ctx, src.ID, files)
 				if err != nil {
-					fmt.Printf("      Error chunking: %v\n", err)
-					continue
+					fmt.Printf("  Error pruning: %v\n", err)
 				}
-				fmt.Printf("      %d chunks\n", len(chunks))
-
-				// Store chunks and embeddings
-				chunkIDs := make(map[int]int64) // chunk index -> db ID
-				for i, c := range chunks {
-					var parentID *int64
-					if c.ParentIndex != nil {
-						if pid, ok := chunkIDs[*c.ParentIndex]; ok {
-							parentID = &pid
-						}
-					}
-
-					dbChunk, err := db.CreateChunk(ctx, dbDoc.ID, parentID, c.Level, c.Title, c.Content, c.TokenCount)
-					if err != nil {
-						fmt.Printf("      Error creating chunk: %v\n", err)
-						continue
-					}
-					chunkIDs[i] = dbChunk.ID
-
-					// Generate and store embedding (skip if content too short)
-					if len(strings.TrimSpace(c.Content)) < 10 {
-						continue
-					}
-					embedding, err := embedClient.Embed(ctx, c.Content)
-					if err != nil {
-						fmt.Printf("      Error embedding: %v\n", err)
-						continue
-					}
-					if err := db.StoreEmbedding(ctx, dbChunk.ID, embedding); err != nil {
-						fmt.Printf("      Error storing embedding: %v\n", err)
-						continue
-					}
+				for _, relPath := range removed {
+					fmt.Printf("    %s: removed\n", relPath)
 				}
+				srcReport.Removed += len(removed)
 			}
+			// Citations link to the commit the documents were read at
+			if revision, err := fetcher.Revision(repoPath); err != nil {
+				fmt.Printf("  Error reading revision: %v\n", err)
+			} else if err := db.SetSourceRevision(ctx, src.ID, revision); err != nil {
+				fmt.Printf("  Error recording revision: %v\n", err)
+			}
+			fmt.Printf("  %s\n", formatReport(srcReport))
+			report.Add(srcReport)
 		}
 
-		fmt.Println("\nIngest complete!")
+		fmt.Printf("\nIngest complete: %s\n", formatReport(report))
 		return nil
 	},
 }
@@ -248,6 +269,17 @@ var queryCmd = &cobra.Command{
 		lang, _ := cmd.Flags().GetString("lang")
 		limit, _ := cmd.Flags().GetInt("limit")
 		vectorOnly, _ := cmd.Flags().GetBool("vector-only")
+		expand, _ := cmd.Flags().GetBool("expand")
+		window, _ := cmd.Flags().GetInt("window")
+		maxTokens, _ := cmd.Flags().GetInt("max-tokens")
+		if maxTokens > 0 && !cmd.Flags().Changed("limit") {
+			// The budget decides how many results fit
+			limit = store.PackCandidates
+		}
+		opts, err := searchOptionsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
 
 		// Open database
 		db, err := store.New(getDBPath())
@@ -257,28 +289,34 @@ var queryCmd = &cobra.Command{
 		defer db.Close()
 
 		// Get language ID if specified
-		var languageID int64
 		if lang != "" {
 			language, err := db.GetLanguage(ctx, lang)
 			if err != nil {
 				return fmt.Errorf("language %q not found: %w", lang, err)
 			}
-			languageID = language.ID
+			opts.LanguageID = language.ID
 		}
 
-		// Get query embedding from Ollama
-		client := embed.New(ollamaURL, "snowflake-arctic-embed:l")
-		queryVec, err := client.Embed(ctx, query)
+		// Get query embedding
+		client, closeEmbedder, err := newEmbedder(embedModel)
+		if err != nil {
+			return fmt.Errorf("create embedder: %w", err)
+		}
+		defer closeEmbedder()
+		queryVec, err := embed.NewEncoder(client).EmbedQuery(ctx, query)
 		if err != nil {
 			return fmt.Errorf("get embedding: %w", err)
 		}
+		if err := db.CheckEmbeddingModel(ctx, client.Model(), len(queryVec)); err != nil {
+			return err
+		}
 
 		// Perform search
 		var results []*store.SearchResult
 		if vectorOnly {
-			results, err = db.SearchChunksVectorWithScore(ctx, queryVec, languageID, limit)
+			results, err = db.SearchChunksVectorWithScore(ctx, queryVec, opts, limit)
 		} else {
-			results, err = db.SearchChunksHybrid(ctx, queryVec, query, languageID, limit)
+			results, err = db.SearchChunksHybrid(ctx, queryVec, query, opts, limit)
 		}
 		if err != nil {
 			return fmt.Errorf("search: %w", err)
@@ -289,6 +327,26 @@ var queryCmd = &cobra.Command{
 			return nil
 		}
 
+		if expand || maxTokens > 0 {
+			if !expand {
+				window = 0 // Still merge adjacent hits, which saves repeated headers
+			}
+			passages, err := db.ExpandResults(ctx, results, window)
+			if err != nil {
+				return fmt.Errorf("expand results: %w", err)
+			}
+			if maxTokens <= 0 {
+				printPassages(query, passages, true)
+				return nil
+			}
+
+			packing := store.PackPassages(passages, maxTokens)
+			printPassages(query, packing.Passages, false)
+			fmt.Printf("Packed %d passages in about %d of %d tokens; dropped %d candidates for space and %d duplicates.\n",
+				len(packing.Passages), packing.Tokens, maxTokens, packing.Dropped, packing.Duplicates)
+			return nil
+		}
+
 		// Display results
 		// Distance is 0-1 where 0=perfect match, so relevance = 1 - distance
 		fmt.Printf("Found %d results for %q:\n\n", len(results), query)
@@ -296,6 +354,11 @@ var queryCmd = &cobra.Command{
 			relevance := 1.0 - r.Distance
 			fmt.Printf("─── Result %d (relevance: %.0f%%) ───\n", i+1, relevance*100)
 			fmt.Printf("Title: %s\n", r.Chunk.Title)
+			fmt.Printf("Source: %s\n", r.Source.String())
+			fmt.Printf("Document: %s\n", r.Citation.String())
+			if r.Citation.URL != "" {
+				fmt.Printf("URL: %s\n", r.Citation.URL)
+			}
 			fmt.Printf("Level: %s\n", r.Chunk.Level)
 			fmt.Printf("\n%s\n\n", truncate(r.Chunk.Content, 500))
 		}
@@ -304,6 +367,73 @@ var queryCmd = &cobra.Command{
 	},
 }
 
+// searchOptionsFromFlags reads the query command's search filters and ranking.
+func searchOptionsFromFlags(cmd *cobra.Command) (store.SearchOptions, error) {
+	var opts store.SearchOptions
+	opts.Sources, _ = cmd.Flags().GetStringSlice("source")
+	opts.ExcludeSources, _ = cmd.Flags().GetStringSlice("exclude-source")
+	opts.Tiers, _ = cmd.Flags().GetIntSlice("tier")
+	opts.Levels, _ = cmd.Flags().GetStringSlice("level")
+	opts.PathPrefix, _ = cmd.Flags().GetString("path")
+	opts.CodeOnly, _ = cmd.Flags().GetBool("code-only")
+	opts.AdvancedQuery, _ = cmd.Flags().GetBool("advanced")
+	opts.Diversity, _ = cmd.Flags().GetFloat64("diversity")
+	opts.MaxPerDocument, _ = cmd.Flags().GetInt("per-document")
+
+	tierWeights, _ := cmd.Flags().GetString("tier-weights")
+	weights, err := store.ParseTierWeights(tierWeights)
+	if err != nil {
+		return opts, fmt.Errorf("--tier-weights: %w", err)
+	}
+	opts.Ranking.TierWeights = weights
+
+	opts.Ranking.Fusion, _ = cmd.Flags().GetString("fusion")
+	opts.Ranking.RRFK, _ = cmd.Flags().GetFloat64("rrf-k")
+	vectorWeight, _ := cmd.Flags().GetFloat64("vector-weight")
+	textWeight, _ := cmd.Flags().GetFloat64("text-weight")
+	symbolWeight, _ := cmd.Flags().GetFloat64("symbol-weight")
+	opts.Ranking.VectorWeight = &vectorWeight
+	opts.Ranking.TextWeight = &textWeight
+	opts.Ranking.SymbolWeight = &symbolWeight
+	opts.Ranking.CandidatePool, _ = cmd.Flags().GetInt("candidates")
+	return opts, nil
+}
+
+// printPassages displays expanded search results, shortening long passages
+// if truncateContent is set.
+func printPassages(query string, passages []*store.Passage, truncateContent bool) {
+	fmt.Printf("Found %d passages for %q:\n\n", len(passages), query)
+	for i, p := range passages {
+		relevance := 1.0 - p.Distance
+		fmt.Printf("─── Result %d (relevance: %.0f%%) ───\n", i+1, relevance*100)
+		fmt.Printf("Section: %s\n", strings.Join(p.Breadcrumbs, " > "))
+		fmt.Printf("Source: %s\n", p.Source.String())
+		fmt.Printf("Document: %s\n", p.Citation.String())
+		if p.Citation.URL != "" {
+			fmt.Printf("URL: %s\n", p.Citation.URL)
+		}
+		fmt.Printf("Chunks: %d (%d matched)\n", len(p.Chunks), len(p.Hits))
+		content := p.Content()
+		switch {
+		case truncateContent:
+			// Allow each merged chunk the length a single result gets
+			content = truncate(content, 500*len(p.Chunks))
+		case p.Truncated:
+			content += "\n(trimmed to fit the token budget)"
+		}
+		fmt.Printf("\n%s\n\n", content)
+	}
+}
+
+// formatReport formats ingest counts for display.
+func formatReport(r ingest.Report) string {
+	s := fmt.Sprintf("%d added, %d updated, %d unchanged, %d removed, %d failed", r.Added, r.Updated, r.Unchanged, r.Removed, r.Failed)
+	if r.Oversize > 0 {
+		s += fmt.Sprintf(" (%d oversize chunks embedded in parts)", r.Oversize)
+	}
+	return s
+}
+
 // truncate truncates a string to maxLen characters with ellipsis.
 func truncate(s string, maxLen int) string {
 	s = strings.TrimSpace(s)
@@ -313,6 +443,78 @@ func truncate(s string, maxLen int) string {
 	return s[:maxLen-3] + "..."
 }
 
+// newEmbedder creates the embedding client selected by the global flags
+// for the given model, backed by the persistent embedding cache unless
+// --no-embed-cache is set. The returned function closes the cache.
+func newEmbedder(model string) (embed.Embedder, func(), error) {
+	url := embedURL
+	if url == "" && embedProvider == embed.ProviderOllama {
+		url = ollamaURL
+	}
+	client, err := embed.New(embed.Config{
+		Provider: embedProvider,
+		BaseURL:  url,
+		Model:    model,
+		APIKey:   os.Getenv("GRIMOIRE_EMBED_API_KEY"),
+		Options: embed.Options{
+			Timeout:    embedTimeout,
+			MaxRetries: &embedRetries,
+		},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if noEmbedCache {
+		return client, func() {}, nil
+	}
+
+	cachePath := getEmbeddingCachePath()
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return nil, nil, fmt.Errorf("create cache dir: %w", err)
+	}
+	cache, err := store.OpenEmbeddingCache(cachePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return embed.NewCached(client, cache), func() { cache.Close() }, nil
+}
+
+// envOr returns the value of the environment variable key, or fallback if unset.
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// envDuration returns the duration in environment variable key, or fallback
+// if it is unset or invalid.
+func envDuration(key string, fallback time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return d
+	}
+	return fallback
+}
+
+// envInt returns the integer in environment variable key, or fallback if it
+// is unset or invalid.
+func envInt(key string, fallback int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return n
+	}
+	return fallback
+}
+
+// envFloat returns the number in environment variable key, or fallback if
+// it is unset or invalid.
+func envFloat(key string, fallback float64) float64 {
+	if f, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		return f
+	}
+	return fallback
+}
+
 // getDBPath returns the database path, using default if not specified.
 func getDBPath() string {
 	if dbPath != "" {
@@ -330,6 +532,12 @@ func getDBPath() string {
 	return filepath.Join(dir, "grimoire.db")
 }
 
+// getEmbeddingCachePath returns the embedding cache path, kept alongside the
+// git cache next to the database.
+func getEmbeddingCachePath() string {
+	return filepath.Join(filepath.Dir(getDBPath()), "cache", "embeddings.db")
+}
+
 // Sources commands
 var sourcesCmd = &cobra.Command{
 	Use:   "sources",
@@ -361,6 +569,230 @@ var sourcesAddCmd = &cobra.Command{
 	},
 }
 
+var sourcesRemoveCmd = &cobra.Command{
+	Use:   "remove <source>",
+	Short: "Remove a source and all of its documents from the knowledge base",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		name := args[0]
+		lang, _ := cmd.Flags().GetString("lang")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		db, err := store.New(getDBPath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer db.Close()
+
+		var languageID int64
+		if lang != "" {
+			language, err := db.GetLanguage(ctx, lang)
+			if err != nil {
+				return fmt.Errorf("language %q not found: %w", lang, err)
+			}
+			languageID = language.ID
+		}
+
+		// Source names are only unique within a language
+		sources, err := db.ListSources(ctx, languageID)
+		if err != nil {
+			return fmt.Errorf("list sources: %w", err)
+		}
+		var matches []*store.Source
+		for _, src := range sources {
+			if src.Name == name {
+				matches = append(matches, src)
+			}
+		}
+		switch {
+		case len(matches) == 0:
+			return fmt.Errorf("source %q not found", name)
+		case len(matches) > 1:
+			return fmt.Errorf("source %q exists in %d languages; use --lang to choose one", name, len(matches))
+		}
+		src := matches[0]
+
+		prompt := fmt.Sprintf("Remove source %s (%s) and all of its documents, chunks and embeddings?", src.Name, src.URL)
+		if !yes && !confirm(cmd, prompt) {
+			fmt.Println("Aborted.")
+			return nil
+		}
+
+		if err := db.DeleteSource(ctx, src.ID); err != nil {
+			return fmt.Errorf("remove source: %w", err)
+		}
+		fmt.Printf("Removed source %s.\n", src.Name)
+		return nil
+	},
+}
+
+// confirm asks the user a yes/no question on the command's input and
+// reports whether they answered yes.
+func confirm(cmd *cobra.Command, prompt string) bool {
+	fmt.Printf("%s [y/N] ", prompt)
+	answer, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+	if err != nil && answer == "" {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// Show command
+var showCmd = &cobra.Command{
+	Use:   "show <source>/<path>[#anchor]",
+	Short: "Show an indexed document, or one section of it",
+	Long: `Show reassembles an indexed document from its chunks and prints it as
+Markdown. With an #anchor, only that section and its subsections are shown.`,
+	Example: `  grimoire show uber-guide/style.md
+  grimoire show uber-guide/style.md#error-wrapping`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		lang, _ := cmd.Flags().GetString("lang")
+
+		ref, anchor, err := parseDocumentRef(args[0])
+		if err != nil {
+			return err
+		}
+		ref.Language = lang
+
+		db, err := store.New(getDBPath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer db.Close()
+
+		doc, err := db.GetDocument(ctx, ref)
+		if err != nil {
+			return fmt.Errorf("get document: %w", err)
+		}
+
+		sections := doc.Sections
+		if anchor != "" {
+			if sections = doc.Section(anchor); sections == nil {
+				return fmt.Errorf("section #%s not found in %s/%s", anchor, ref.Source, ref.Path)
+			}
+		}
+
+		fmt.Printf("# %s\n\n", doc.Document.Title)
+		fmt.Printf("Source: %s (%s)\n", doc.Source.String(), doc.Language)
+		url := doc.URL
+		if anchor != "" {
+			url = sections[0].URL
+		}
+		if url != "" {
+			fmt.Printf("URL: %s\n", url)
+		}
+		if anchor == "" && doc.Introduction != "" {
+			fmt.Printf("\n%s\n", doc.Introduction)
+		}
+		for _, sec := range sections {
+			level := sec.Level
+			if level == 0 {
+				level = 2 // Indexed before heading levels were recorded
+			}
+			fmt.Printf("\n%s %s\n", strings.Repeat("#", level), sec.Title)
+			if sec.Content != "" {
+				fmt.Printf("\n%s\n", sec.Content)
+			}
+		}
+		return nil
+	},
+}
+
+// parseDocumentRef splits a <source>/<path>[#anchor] argument.
+func parseDocumentRef(arg string) (store.DocumentRef, string, error) {
+	location, anchor, _ := strings.Cut(arg, "#")
+	source, path, ok := strings.Cut(location, "/")
+	if !ok || source == "" || path == "" {
+		return store.DocumentRef{}, "", fmt.Errorf("invalid document %q: want <source>/<path>[#anchor]", arg)
+	}
+	return store.DocumentRef{Source: source, Path: path}, anchor, nil
+}
+
+// Topics command
+var topicsCmd = &cobra.Command{
+	Use:   "topics [topic]",
+	Short: "Browse the topics covered by indexed documents",
+	Long: `Topics lists document titles and the headings under them, with the
+number of documents covering each. Give a topic, such as "Effective Go > Errors",
+to browse the topics under it.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		lang, _ := cmd.Flags().GetString("lang")
+		sources, _ := cmd.Flags().GetStringSlice("source")
+		depth, _ := cmd.Flags().GetInt("depth")
+
+		opts := store.TopicOptions{Sources: sources, Depth: depth}
+		if len(args) == 1 {
+			opts.Parent = parseTopic(args[0])
+		}
+
+		db, err := store.New(getDBPath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer db.Close()
+
+		var languages []*store.Language
+		if lang != "" {
+			language, err := db.GetLanguage(ctx, lang)
+			if err != nil {
+				return fmt.Errorf("language %q not found: %w", lang, err)
+			}
+			languages = []*store.Language{language}
+		} else if languages, err = db.ListLanguages(ctx); err != nil {
+			return fmt.Errorf("list languages: %w", err)
+		}
+
+		var found bool
+		for _, language := range languages {
+			opts.LanguageID = language.ID
+			topics, err := db.ListTopics(ctx, opts)
+			if errors.Is(err, store.ErrNotFound) && lang == "" {
+				continue // Sources of another language
+			}
+			if err != nil {
+				return fmt.Errorf("list topics: %w", err)
+			}
+			if len(topics) == 0 {
+				continue
+			}
+
+			if found {
+				fmt.Println()
+			}
+			found = true
+			fmt.Printf("Topics for %s (documents covering each):\n\n", language.DisplayName)
+			printTopics(topics, 0)
+		}
+		if !found {
+			fmt.Println("No topics found.")
+		}
+		return nil
+	},
+}
+
+// parseTopic splits a topic path such as "Effective Go > Errors".
+func parseTopic(topic string) []string {
+	names := strings.Split(topic, ">")
+	for i, name := range names {
+		names[i] = strings.TrimSpace(name)
+	}
+	return names
+}
+
+// printTopics prints a topic tree, indenting subtopics.
+func printTopics(topics []*store.Topic, indent int) {
+	for _, t := range topics {
+		fmt.Printf("%s%s (%d)\n", strings.Repeat("  ", indent), t.Name, t.Documents)
+		printTopics(t.Children, indent+1)
+	}
+}
+
 // Stats command
 var statsCmd = &cobra.Command{
 	Use:   "stats",
@@ -387,6 +819,230 @@ var statsCmd = &cobra.Command{
 		fmt.Printf("  Documents:  %d\n", stats.Documents)
 		fmt.Printf("  Chunks:     %d\n", stats.Chunks)
 		fmt.Printf("  Embeddings: %d\n", stats.Embeddings)
+		if stats.EmbeddingModel != "" {
+			fmt.Printf("  Model:      %s (%d dimensions)\n", stats.EmbeddingModel, stats.EmbeddingDimensions)
+		}
+
+		// Report on the embedding cache without creating one
+		cachePath := getEmbeddingCachePath()
+		if _, err := os.Stat(cachePath); err != nil {
+			return nil
+		}
+		cache, err := store.OpenEmbeddingCache(cachePath)
+		if err != nil {
+			return err
+		}
+		defer cache.Close()
+
+		cacheStats, err := cache.Stats(ctx)
+		if err != nil {
+			return fmt.Errorf("get cache stats: %w", err)
+		}
+
+		fmt.Printf("\nEmbedding cache: %s\n\n", cachePath)
+		fmt.Printf("  Entries:    %d (%d models, %.1f MB)\n", cacheStats.Entries, cacheStats.Models, float64(cacheStats.Bytes)/(1<<20))
+		fmt.Printf("  Hits:       %d\n", cacheStats.Hits)
+		fmt.Printf("  Misses:     %d\n", cacheStats.Misses)
+		if lookups := cacheStats.Hits + cacheStats.Misses; lookups > 0 {
+			fmt.Printf("  Hit rate:   %.1f%%\n", 100*float64(cacheStats.Hits)/float64(lookups))
+		}
+		return nil
+	},
+}
+
+// Cache commands
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the embedding cache",
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove unused or stale entries from the embedding cache",
+	Long: `Remove embedding cache entries that have not been used recently, and
+optionally every entry for a model other than the current --embed-model.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		olderThan, _ := cmd.Flags().GetDuration("older-than")
+		otherModels, _ := cmd.Flags().GetBool("other-models")
+
+		cachePath := getEmbeddingCachePath()
+		if _, err := os.Stat(cachePath); err != nil {
+			fmt.Println("Embedding cache is empty.")
+			return nil
+		}
+		cache, err := store.OpenEmbeddingCache(cachePath)
+		if err != nil {
+			return err
+		}
+		defer cache.Close()
+
+		var opts store.CachePruneOptions
+		if olderThan > 0 {
+			opts.UnusedSince = time.Now().Add(-olderThan)
+		}
+		if otherModels {
+			opts.KeepModel = embedModel
+		}
+
+		removed, err := cache.Prune(ctx, opts)
+		if err != nil {
+			return fmt.Errorf("prune cache: %w", err)
+		}
+		fmt.Printf("Removed %d cached embeddings.\n", removed)
+		return nil
+	},
+}
+
+// Reembed command
+var reembedCmd = &cobra.Command{
+	Use:   "reembed",
+	Short: "Re-embed all chunks with a new embedding model",
+	Long: `Generate new embeddings for every stored chunk with a different model.
+
+New vectors are written to a staging table while the old ones keep serving
+queries, and are swapped in atomically once every chunk has been converted.
+If interrupted, running the same command again resumes where it stopped.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		model, _ := cmd.Flags().GetString("model")
+		batchSize, _ := cmd.Flags().GetInt("batch-size")
+		if batchSize <= 0 {
+			return fmt.Errorf("--batch-size must be positive")
+		}
+
+		db, err := store.New(getDBPath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer db.Close()
+
+		if current, err := db.EmbeddingModel(ctx); err == nil && current.Name == model {
+			fmt.Printf("Database already uses %s.\n", model)
+			return nil
+		}
+
+		embedder, closeEmbedder, err := newEmbedder(model)
+		if err != nil {
+			return fmt.Errorf("create embedder: %w", err)
+		}
+		defer closeEmbedder()
+		encoder := embed.NewEncoder(embedder)
+
+		// Resume an interrupted run, or probe the model for its dimension
+		var dims int
+		if target, err := db.ReembedTarget(ctx); err == nil && target.Name == model {
+			dims = target.Dimensions
+			fmt.Printf("Resuming re-embed with %s\n", model)
+		} else {
+			probe, err := encoder.EmbedDocuments(ctx, []string{"dimension probe"})
+			if err != nil {
+				return fmt.Errorf("get embedding: %w", err)
+			}
+			dims = len(probe[0])
+			fmt.Printf("Re-embedding with %s (%d dimensions)\n", model, dims)
+		}
+
+		if err := db.BeginReembed(ctx, model, dims); err != nil {
+			return fmt.Errorf("begin re-embed: %w", err)
+		}
+
+		done, total, lastID, err := db.ReembedProgress(ctx)
+		if err != nil {
+			return fmt.Errorf("get progress: %w", err)
+		}
+
+		for {
+			batch, err := db.NextReembedBatch(ctx, lastID, batchSize)
+			if err != nil {
+				return fmt.Errorf("load chunks: %w", err)
+			}
+			if len(batch) == 0 {
+				break
+			}
+
+			ids := make([]int64, len(batch))
+			texts := make([]string, len(batch))
+			for i, c := range batch {
+				ids[i] = c.ID
+				texts[i] = c.Content
+			}
+
+			embeddings, err := encoder.EmbedDocuments(ctx, texts)
+			if err != nil {
+				return fmt.Errorf("embed chunks %d-%d: %w", ids[0], ids[len(ids)-1], err)
+			}
+			if err := db.StoreReembedBatch(ctx, ids, embeddings); err != nil {
+				return fmt.Errorf("store embeddings: %w", err)
+			}
+
+			lastID = ids[len(ids)-1]
+			done += int64(len(batch))
+			fmt.Printf("  %d/%d chunks\n", done, total)
+		}
+
+		if err := db.FinishReembed(ctx); err != nil {
+			return fmt.Errorf("finish re-embed: %w", err)
+		}
+
+		fmt.Printf("\nRe-embed complete. Use --embed-model %s (or GRIMOIRE_EMBED_MODEL) for future ingests and queries.\n", model)
+		return nil
+	},
+}
+
+// Database commands
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Manage the knowledge base database",
+}
+
+var dbMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Apply pending schema migrations",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		// Open without migrating so pending changes can be reported first
+		db, err := store.Open(getDBPath())
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer db.Close()
+
+		current, err := db.SchemaVersion(ctx)
+		if err != nil {
+			return fmt.Errorf("get schema version: %w", err)
+		}
+
+		pending, err := db.PendingMigrations(ctx)
+		if err != nil {
+			return fmt.Errorf("check migrations: %w", err)
+		}
+
+		fmt.Printf("Database: %s\n", getDBPath())
+		fmt.Printf("Schema version: %d (latest: %d)\n", current, store.LatestSchemaVersion())
+
+		if len(pending) == 0 {
+			fmt.Println("Database is up to date.")
+			return nil
+		}
+
+		fmt.Printf("\nPending migrations:\n")
+		for _, m := range pending {
+			fmt.Printf("  %3d  %s\n", m.Version, m.Name)
+		}
+
+		if dryRun {
+			fmt.Println("\nDry run: no changes made.")
+			return nil
+		}
+
+		applied, err := db.Migrate(ctx)
+		if err != nil {
+			return fmt.Errorf("migrate: %w", err)
+		}
+		fmt.Printf("\nApplied %d migrations.\n", len(applied))
 		return nil
 	},
 }
@@ -394,29 +1050,90 @@ var statsCmd = &cobra.Command{
 func init() {
 	// Global flags
 	rootCmd.PersistentFlags().StringVar(&dbPath, "db", "", "Database path (default: ~/.grimoire/grimoire.db)")
-	rootCmd.PersistentFlags().StringVar(&ollamaURL, "ollama-url", "http://localhost:11434", "Ollama API URL")
+	rootCmd.PersistentFlags().StringVar(&ollamaURL, "ollama-url", envOr("OLLAMA_URL", embed.DefaultOllamaURL), "Ollama API URL")
+	rootCmd.PersistentFlags().MarkDeprecated("ollama-url", "use --embed-url instead")
+	rootCmd.PersistentFlags().StringVar(&embedProvider, "embed-provider", envOr("GRIMOIRE_EMBED_PROVIDER", embed.DefaultProvider), "Embedding provider (ollama or openai)")
+	rootCmd.PersistentFlags().StringVar(&embedURL, "embed-url", os.Getenv("GRIMOIRE_EMBED_URL"), "Embedding API URL (default depends on provider)")
+	rootCmd.PersistentFlags().StringVar(&embedModel, "embed-model", envOr("GRIMOIRE_EMBED_MODEL", embed.DefaultModel), "Embedding model name")
+	rootCmd.PersistentFlags().DurationVar(&embedTimeout, "embed-timeout", envDuration("GRIMOIRE_EMBED_TIMEOUT", embed.DefaultTimeout), "Timeout for each embedding request")
+	rootCmd.PersistentFlags().IntVar(&embedRetries, "embed-retries", envInt("GRIMOIRE_EMBED_RETRIES", embed.DefaultMaxRetries), "Retries for rate-limited or failed embedding requests")
+	rootCmd.PersistentFlags().BoolVar(&noEmbedCache, "no-embed-cache", false, "Bypass the persistent embedding cache")
 
 	// Add language commands
 	rootCmd.AddCommand(languagesCmd)
 	languagesCmd.AddCommand(languagesListCmd)
 	languagesCmd.AddCommand(languagesInstallCmd)
+	languagesCmd.AddCommand(languagesRemoveCmd)
+	languagesRemoveCmd.Flags().BoolP("yes", "y", false, "Skip the confirmation prompt")
 
 	// Add ingest command
 	rootCmd.AddCommand(ingestCmd)
 	ingestCmd.Flags().String("source", "", "Filter by source name")
+	ingestCmd.Flags().Bool("no-prune", false, "Keep documents that no longer exist in the source")
+	ingestCmd.Flags().Int("batch-size", ingest.DefaultBatchSize, "Number of chunks to embed per request")
+	ingestCmd.Flags().Int("concurrency", ingest.DefaultConcurrency, "Maximum concurrent embedding requests")
 
 	// Add query command
 	rootCmd.AddCommand(queryCmd)
 	queryCmd.Flags().String("lang", "", "Filter by language")
 	queryCmd.Flags().Int("limit", 5, "Maximum number of results")
 	queryCmd.Flags().Bool("vector-only", false, "Use vector search only (no FTS)")
+	queryCmd.Flags().StringSlice("source", nil, "Only search these sources (repeatable)")
+	queryCmd.Flags().StringSlice("exclude-source", nil, "Skip these sources (repeatable)")
+	queryCmd.Flags().IntSlice("tier", nil, "Only search sources of these tiers (repeatable)")
+	queryCmd.Flags().StringSlice("level", nil, "Only return chunks of these levels: summary, section, paragraph")
+	queryCmd.Flags().String("path", "", "Only search documents whose path starts with this prefix")
+	queryCmd.Flags().Bool("code-only", false, "Only return chunks containing code")
+	queryCmd.Flags().Bool("advanced", false, "Pass the query to full-text search as raw FTS5 syntax")
+	queryCmd.Flags().Bool("expand", false, "Widen results with neighbouring chunks and merge adjacent hits into passages")
+	queryCmd.Flags().Int("window", 1, "Neighbouring chunks on each side of a hit to include with --expand")
+	queryCmd.Flags().Int("max-tokens", 0, "Pack the best passages into about this many tokens (0 for no budget)")
+	queryCmd.Flags().Float64("diversity", 0, "Trade relevance for variety from 0 to 1, demoting near-duplicate results")
+	queryCmd.Flags().Int("per-document", 0, "Maximum results from one document (0 for no limit)")
+	queryCmd.Flags().String("fusion", envOr("GRIMOIRE_FUSION", store.DefaultFusion), "How to combine vector and full-text rankings: rrf or linear")
+	queryCmd.Flags().Float64("rrf-k", envFloat("GRIMOIRE_RRF_K", store.DefaultRRFK), "Rank constant for rrf fusion; larger values flatten rank differences")
+	queryCmd.Flags().Float64("vector-weight", envFloat("GRIMOIRE_VECTOR_WEIGHT", 1), "Weight of the vector ranking in fusion")
+	queryCmd.Flags().Float64("text-weight", envFloat("GRIMOIRE_TEXT_WEIGHT", 1), "Weight of the full-text ranking in fusion")
+	queryCmd.Flags().Float64("symbol-weight", envFloat("GRIMOIRE_SYMBOL_WEIGHT", 1), "Weight of the code identifier ranking in fusion")
+	queryCmd.Flags().Int("candidates", envInt("GRIMOIRE_CANDIDATES", store.DefaultCandidatePool), "Results fetched from each retriever before fusion")
+	queryCmd.Flags().String("tier-weights", os.Getenv("GRIMOIRE_TIER_WEIGHTS"), "Hybrid score multiplier per source tier, e.g. 1=1.0,2=0.9 (default 1=1,2=0.95,3=0.9,4=0.85,5=0.8)")
 
 	// Add sources commands
 	rootCmd.AddCommand(sourcesCmd)
 	sourcesCmd.AddCommand(sourcesListCmd)
 	sourcesCmd.AddCommand(sourcesAddCmd)
 	sourcesListCmd.Flags().String("lang", "", "Filter by language")
+	sourcesCmd.AddCommand(sourcesRemoveCmd)
+	sourcesRemoveCmd.Flags().String("lang", "", "Language the source belongs to")
+	sourcesRemoveCmd.Flags().BoolP("yes", "y", false, "Skip the confirmation prompt")
+
+	// Add show command
+	rootCmd.AddCommand(showCmd)
+	showCmd.Flags().String("lang", "", "Language the source belongs to")
+
+	// Add topics command
+	rootCmd.AddCommand(topicsCmd)
+	topicsCmd.Flags().String("lang", "", "Filter by language")
+	topicsCmd.Flags().StringSlice("source", nil, "Only list topics of these sources (repeatable)")
+	topicsCmd.Flags().Int("depth", 2, "Levels of topics to list (0 for all)")
 
 	// Add stats command
 	rootCmd.AddCommand(statsCmd)
+
+	// Add cache commands
+	cachePruneCmd.Flags().Duration("older-than", 30*24*time.Hour, "Remove entries not used within this duration (0 to keep all)")
+	cachePruneCmd.Flags().Bool("other-models", false, "Remove entries for models other than --embed-model")
+	cacheCmd.AddCommand(cachePruneCmd)
+	rootCmd.AddCommand(cacheCmd)
+
+	// Add reembed command
+	rootCmd.AddCommand(reembedCmd)
+	reembedCmd.Flags().String("model", "", "Embedding model to migrate to")
+	reembedCmd.Flags().Int("batch-size", 32, "Number of chunks to embed per request")
+	reembedCmd.MarkFlagRequired("model")
+
+	// Add database commands
+	rootCmd.AddCommand(dbCmd)
+	dbCmd.AddCommand(dbMigrateCmd)
+	dbMigrateCmd.Flags().Bool("dry-run", false, "List pending migrations without applying them")
 }
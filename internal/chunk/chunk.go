@@ -9,12 +9,13 @@ import (
 
 // Chunk represents a piece of content from a document.
 type Chunk struct {
-	Level       string   // "summary", "section", "paragraph"
+	Level       string // "summary", "section", "paragraph"
 	Title       string
 	Content     string
 	TokenCount  int
 	ParentIndex *int
 	Breadcrumbs []string
+	Anchor      string // Anchor of the section heading; empty for the summary
 }
 
 // Chunker splits documents into hierarchical chunks.
@@ -87,6 +88,7 @@ func (c *Chunker) Chunk(doc *parse.Document) ([]Chunk, error) {
 				TokenCount:  tokens,
 				ParentIndex: &summaryIdx,
 				Breadcrumbs: breadcrumbs,
+				Anchor:      section.Heading.Anchor,
 			}
 			chunks = append(chunks, sectionChunk)
 		} else {
@@ -117,6 +119,7 @@ func (c *Chunker) Chunk(doc *parse.Document) ([]Chunk, error) {
 								TokenCount:  c.CountTokens(current),
 								ParentIndex: &summaryIdx,
 								Breadcrumbs: breadcrumbs,
+								Anchor:      section.Heading.Anchor,
 							}
 							chunks = append(chunks, paraChunk)
 							current = sent
@@ -132,6 +135,7 @@ func (c *Chunker) Chunk(doc *parse.Document) ([]Chunk, error) {
 							TokenCount:  c.CountTokens(current),
 							ParentIndex: &summaryIdx,
 							Breadcrumbs: breadcrumbs,
+							Anchor:      section.Heading.Anchor,
 						}
 						chunks = append(chunks, paraChunk)
 					}
@@ -143,6 +147,7 @@ func (c *Chunker) Chunk(doc *parse.Document) ([]Chunk, error) {
 						TokenCount:  paraTokens,
 						ParentIndex: &summaryIdx,
 						Breadcrumbs: breadcrumbs,
+						Anchor:      section.Heading.Anchor,
 					}
 					chunks = append(chunks, paraChunk)
 				}
@@ -153,11 +158,18 @@ func (c *Chunker) Chunk(doc *parse.Document) ([]Chunk, error) {
 	return chunks, nil
 }
 
-// CountTokens estimates the number of tokens in text.
-// Uses a simple approximation of ~4 characters per token.
+// CharsPerToken is the number of characters assumed per token when
+// estimating token counts.
+const CharsPerToken = 4
+
+// EstimateTokens estimates the number of tokens in text from its length.
+func EstimateTokens(text string) int {
+	return (len(text) + CharsPerToken - 1) / CharsPerToken
+}
+
+// CountTokens estimates the number of tokens in text, as EstimateTokens.
 func (c *Chunker) CountTokens(text string) int {
-	// Simple approximation: ~4 characters per token
-	return (len(text) + 3) / 4
+	return EstimateTokens(text)
 }
 
 // splitIntoParagraphs splits text on double newlines.
@@ -162,6 +162,9 @@ Content here.
 	if len(subsectionChunk.Breadcrumbs) < 2 {
 		t.Errorf("Breadcrumbs = %v, want at least 2 entries", subsectionChunk.Breadcrumbs)
 	}
+	if subsectionChunk.Anchor != "subsection-a1" {
+		t.Errorf("Anchor = %q, want %q", subsectionChunk.Anchor, "subsection-a1")
+	}
 }
 
 func generateLargeContent(words int) string {
@@ -0,0 +1,103 @@
+package embed
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// Cache stores embeddings keyed by model name and TextHash.
+type Cache interface {
+	// Lookup returns the cached embeddings among hashes, keyed by hash.
+	Lookup(ctx context.Context, model string, hashes []string) (map[string][]float32, error)
+	// Store saves embeddings keyed by hash.
+	Store(ctx context.Context, model string, entries map[string][]float32) error
+}
+
+// TextHash returns the cache key for text: its hex-encoded SHA-256 hash.
+func TextHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// CachedEmbedder wraps an Embedder and serves previously embedded texts from
+// a Cache, sending only misses to the provider.
+type CachedEmbedder struct {
+	embedder Embedder
+	cache    Cache
+}
+
+// Ensure CachedEmbedder implements Embedder.
+var _ Embedder = (*CachedEmbedder)(nil)
+
+// NewCached wraps embedder with cache.
+func NewCached(embedder Embedder, cache Cache) *CachedEmbedder {
+	return &CachedEmbedder{embedder: embedder, cache: cache}
+}
+
+// Model returns the wrapped embedder's model name.
+func (c *CachedEmbedder) Model() string {
+	return c.embedder.Model()
+}
+
+// Embed generates an embedding for a single text, using the cache if possible.
+func (c *CachedEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	embeddings, err := c.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// EmbedBatch generates embeddings for multiple texts. Cached texts are served
+// directly; the rest, deduplicated, are embedded in one call and cached.
+func (c *CachedEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	model := c.embedder.Model()
+
+	hashes := make([]string, len(texts))
+	for i, text := range texts {
+		hashes[i] = TextHash(text)
+	}
+
+	// The cache only saves work, so a failing cache counts as a miss
+	cached, err := c.cache.Lookup(ctx, model, hashes)
+	if err != nil || cached == nil {
+		cached = make(map[string][]float32, len(texts))
+	}
+
+	var missTexts, missHashes []string
+	queued := make(map[string]bool)
+	for i, h := range hashes {
+		if _, ok := cached[h]; ok || queued[h] {
+			continue
+		}
+		queued[h] = true
+		missTexts = append(missTexts, texts[i])
+		missHashes = append(missHashes, h)
+	}
+
+	if len(missTexts) > 0 {
+		embeddings, err := c.embedder.EmbedBatch(ctx, missTexts)
+		if err != nil {
+			return nil, err
+		}
+		if len(embeddings) != len(missTexts) {
+			return nil, fmt.Errorf("got %d embeddings for %d inputs", len(embeddings), len(missTexts))
+		}
+
+		fresh := make(map[string][]float32, len(missHashes))
+		for i, h := range missHashes {
+			fresh[h] = embeddings[i]
+			cached[h] = embeddings[i]
+		}
+		// Texts that fail to cache are embedded again next time
+		_ = c.cache.Store(ctx, model, fresh)
+	}
+
+	result := make([][]float32, len(texts))
+	for i, h := range hashes {
+		result[i] = cached[h]
+	}
+	return result, nil
+}
@@ -0,0 +1,128 @@
+package embed_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jamesainslie/grimoire/internal/embed"
+)
+
+// countingEmbedder embeds each text as its length and records the texts sent.
+type countingEmbedder struct {
+	sent []string
+}
+
+func (e *countingEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	embeddings, err := e.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+func (e *countingEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	e.sent = append(e.sent, texts...)
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embeddings[i] = []float32{float32(len(text))}
+	}
+	return embeddings, nil
+}
+
+func (e *countingEmbedder) Model() string {
+	return "counting"
+}
+
+// mapCache is an in-memory embed.Cache.
+type mapCache map[string][]float32
+
+func (c mapCache) Lookup(ctx context.Context, model string, hashes []string) (map[string][]float32, error) {
+	found := make(map[string][]float32)
+	for _, h := range hashes {
+		if v, ok := c[model+"/"+h]; ok {
+			found[h] = v
+		}
+	}
+	return found, nil
+}
+
+func (c mapCache) Store(ctx context.Context, model string, entries map[string][]float32) error {
+	for h, v := range entries {
+		c[model+"/"+h] = v
+	}
+	return nil
+}
+
+func TestCachedEmbedder_EmbedBatch(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	inner := &countingEmbedder{}
+	cache := mapCache{}
+	embedder := embed.NewCached(inner, cache)
+
+	// Duplicates within a batch are embedded once
+	got, err := embedder.EmbedBatch(ctx, []string{"a", "bb", "a"})
+	if err != nil {
+		t.Fatalf("EmbedBatch() error = %v", err)
+	}
+	if len(got) != 3 || got[0][0] != 1 || got[1][0] != 2 || got[2][0] != 1 {
+		t.Errorf("EmbedBatch() = %v, want [[1] [2] [1]]", got)
+	}
+	if len(inner.sent) != 2 {
+		t.Errorf("provider received %v, want 2 texts", inner.sent)
+	}
+
+	// Cached texts are not sent again
+	got, err = embedder.EmbedBatch(ctx, []string{"bb", "ccc"})
+	if err != nil {
+		t.Fatalf("EmbedBatch() error = %v", err)
+	}
+	if got[0][0] != 2 || got[1][0] != 3 {
+		t.Errorf("EmbedBatch() = %v, want [[2] [3]]", got)
+	}
+	if len(inner.sent) != 3 || inner.sent[2] != "ccc" {
+		t.Errorf("provider received %v, want only ccc added", inner.sent)
+	}
+
+	if _, err := embedder.Embed(ctx, "ccc"); err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	if len(inner.sent) != 3 {
+		t.Errorf("Embed(cached) reached the provider")
+	}
+	if len(cache) != 3 {
+		t.Errorf("cache has %d entries, want 3", len(cache))
+	}
+}
+
+// failingCache is an embed.Cache whose every call fails.
+type failingCache struct{}
+
+func (failingCache) Lookup(ctx context.Context, model string, hashes []string) (map[string][]float32, error) {
+	return nil, errors.New("database is locked")
+}
+
+func (failingCache) Store(ctx context.Context, model string, entries map[string][]float32) error {
+	return errors.New("database is locked")
+}
+
+func TestCachedEmbedder_CacheFailure(t *testing.T) {
+	t.Parallel()
+
+	inner := &countingEmbedder{}
+	embedder := embed.NewCached(inner, failingCache{})
+
+	// A broken cache costs a provider call, not the embedding
+	got, err := embedder.EmbedBatch(context.Background(), []string{"a", "bb"})
+	if err != nil {
+		t.Fatalf("EmbedBatch() error = %v", err)
+	}
+	if len(got) != 2 || got[0][0] != 1 || got[1][0] != 2 {
+		t.Errorf("EmbedBatch() = %v, want [[1] [2]]", got)
+	}
+	if len(inner.sent) != 2 {
+		t.Errorf("provider received %v, want both texts", inner.sent)
+	}
+}
@@ -1,94 +1,66 @@
-// Package embed provides an embedding client for generating vector embeddings.
+// Package embed provides embedding clients for generating vector embeddings.
 package embed
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"net/http"
 )
 
-// Client generates embeddings using an Ollama-compatible API.
-type Client struct {
-	baseURL string
-	model   string
-	http    *http.Client
-}
-
-// New creates a new embedding client.
-// baseURL is the Ollama API URL (e.g., "http://localhost:11434").
-// model is the embedding model name (e.g., "snowflake-arctic-embed:l").
-func New(baseURL, model string) *Client {
-	return &Client{
-		baseURL: baseURL,
-		model:   model,
-		http:    &http.Client{},
-	}
-}
+// Supported embedding providers.
+const (
+	ProviderOllama = "ollama" // Ollama's native /api/embed endpoint
+	ProviderOpenAI = "openai" // OpenAI-compatible /v1/embeddings (llama.cpp, vLLM, LocalAI, ...)
+)
 
-// embedRequest is the request body for the Ollama embed API.
-type embedRequest struct {
-	Model string `json:"model"`
-	Input any    `json:"input"` // string for single, []string for batch
-}
+// Defaults used when a Config field is left empty.
+const (
+	DefaultProvider  = ProviderOllama
+	DefaultModel     = "snowflake-arctic-embed:l"
+	DefaultOllamaURL = "http://localhost:11434"
+	DefaultOpenAIURL = "http://localhost:8080"
+)
 
-// embedResponse is the response from the Ollama embed API.
-type embedResponse struct {
-	Embeddings [][]float32 `json:"embeddings"`
+// Embedder generates vector embeddings for text.
+type Embedder interface {
+	// Embed generates an embedding for a single text.
+	Embed(ctx context.Context, text string) ([]float32, error)
+	// EmbedBatch generates embeddings for multiple texts, in input order.
+	EmbedBatch(ctx context.Context, texts []string) ([][]float32, error)
+	// Model returns the name of the embedding model.
+	Model() string
 }
 
-// Embed generates an embedding for a single text.
-func (c *Client) Embed(ctx context.Context, text string) ([]float32, error) {
-	embeddings, err := c.embed(ctx, text)
-	if err != nil {
-		return nil, err
-	}
-
-	if len(embeddings) == 0 {
-		return nil, fmt.Errorf("no embeddings returned")
-	}
-
-	return embeddings[0], nil
-}
+// Config selects and configures an embedding provider.
+type Config struct {
+	Provider string // ProviderOllama or ProviderOpenAI
+	BaseURL  string // API base URL; defaults depend on the provider
+	Model    string // Embedding model name
+	APIKey   string // Bearer token for OpenAI-compatible servers, if required
 
-// EmbedBatch generates embeddings for multiple texts in a single API call.
-func (c *Client) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
-	return c.embed(ctx, texts)
+	Options // Timeouts and retry policy
 }
 
-// embed sends an embedding request to the Ollama API.
-func (c *Client) embed(ctx context.Context, input any) ([][]float32, error) {
-	reqBody := embedRequest{
-		Model: c.model,
-		Input: input,
-	}
-
-	body, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("marshal request: %w", err)
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/embed", bytes.NewReader(body))
-	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
+// New creates an Embedder for the configured provider.
+func New(cfg Config) (Embedder, error) {
+	if cfg.Provider == "" {
+		cfg.Provider = DefaultProvider
 	}
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.http.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("send request: %w", err)
+	if cfg.Model == "" {
+		cfg.Model = DefaultModel
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("ollama API error: status %d", resp.StatusCode)
+	switch cfg.Provider {
+	case ProviderOllama:
+		if cfg.BaseURL == "" {
+			cfg.BaseURL = DefaultOllamaURL
+		}
+		return NewOllama(cfg.BaseURL, cfg.Model, cfg.Options), nil
+	case ProviderOpenAI:
+		if cfg.BaseURL == "" {
+			cfg.BaseURL = DefaultOpenAIURL
+		}
+		return NewOpenAI(cfg.BaseURL, cfg.Model, cfg.APIKey, cfg.Options), nil
+	default:
+		return nil, fmt.Errorf("unknown embedding provider %q (must be %q or %q)", cfg.Provider, ProviderOllama, ProviderOpenAI)
 	}
-
-	var embedResp embedResponse
-	if err := json.NewDecoder(resp.Body).Decode(&embedResp); err != nil {
-		return nil, fmt.Errorf("decode response: %w", err)
-	}
-
-	return embedResp.Embeddings, nil
 }
@@ -10,7 +10,7 @@ import (
 	"github.com/jamesainslie/grimoire/internal/embed"
 )
 
-func TestClient_Embed(t *testing.T) {
+func TestOllamaClient_Embed(t *testing.T) {
 	t.Parallel()
 
 	// Create mock Ollama server
@@ -47,7 +47,7 @@ func TestClient_Embed(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := embed.New(server.URL, "snowflake-arctic-embed:l")
+	client := embed.NewOllama(server.URL, "snowflake-arctic-embed:l", embed.Options{})
 
 	embedding, err := client.Embed(context.Background(), "test text")
 	if err != nil {
@@ -63,7 +63,7 @@ func TestClient_Embed(t *testing.T) {
 	}
 }
 
-func TestClient_EmbedBatch(t *testing.T) {
+func TestOllamaClient_EmbedBatch(t *testing.T) {
 	t.Parallel()
 
 	callCount := 0
@@ -96,7 +96,7 @@ func TestClient_EmbedBatch(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := embed.New(server.URL, "snowflake-arctic-embed:l")
+	client := embed.NewOllama(server.URL, "snowflake-arctic-embed:l", embed.Options{})
 
 	texts := []string{"text one", "text two", "text three"}
 	embeddings, err := client.EmbedBatch(context.Background(), texts)
@@ -121,7 +121,7 @@ func TestClient_EmbedBatch(t *testing.T) {
 	}
 }
 
-func TestClient_EmbedServerError(t *testing.T) {
+func TestOllamaClient_EmbedServerError(t *testing.T) {
 	t.Parallel()
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -129,10 +129,54 @@ func TestClient_EmbedServerError(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := embed.New(server.URL, "snowflake-arctic-embed:l")
+	client := embed.NewOllama(server.URL, "snowflake-arctic-embed:l", fastRetry)
 
 	_, err := client.Embed(context.Background(), "test")
 	if err == nil {
 		t.Error("Embed() expected error for server error, got nil")
 	}
 }
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		cfg       embed.Config
+		wantModel string
+		wantErr   bool
+	}{
+		{
+			name:      "defaults to ollama",
+			cfg:       embed.Config{},
+			wantModel: embed.DefaultModel,
+		},
+		{
+			name:      "openai-compatible provider",
+			cfg:       embed.Config{Provider: embed.ProviderOpenAI, Model: "nomic-embed-text"},
+			wantModel: "nomic-embed-text",
+		},
+		{
+			name:    "unknown provider",
+			cfg:     embed.Config{Provider: "bogus"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			e, err := embed.New(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if e.Model() != tt.wantModel {
+				t.Errorf("New().Model() = %q, want %q", e.Model(), tt.wantModel)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package embed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults used when an Options field is left zero.
+const (
+	DefaultTimeout    = 2 * time.Minute // Generous enough for a cold model load
+	DefaultMaxRetries = 3
+	DefaultMinBackoff = 500 * time.Millisecond
+	DefaultMaxBackoff = 30 * time.Second
+)
+
+// maxErrorBody caps how much of an error response is read into an APIError.
+const maxErrorBody = 4096
+
+// ErrModelNotFound is returned when the server does not have the requested model.
+var ErrModelNotFound = errors.New("embedding model not found")
+
+// Options configures timeouts and retries for embedding API requests.
+type Options struct {
+	Timeout    time.Duration // Per-attempt timeout, including reading the response
+	MaxRetries *int          // Retries after the first attempt; nil for DefaultMaxRetries, 0 disables retries
+	MinBackoff time.Duration // Base delay before the first retry
+	MaxBackoff time.Duration // Upper bound on the delay between retries
+}
+
+// withDefaults returns a copy of o with zero fields, and a nil MaxRetries,
+// set to their defaults.
+func (o Options) withDefaults() Options {
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultTimeout
+	}
+	retries := DefaultMaxRetries
+	if o.MaxRetries != nil {
+		retries = max(*o.MaxRetries, 0)
+	}
+	o.MaxRetries = &retries
+	if o.MinBackoff <= 0 {
+		o.MinBackoff = DefaultMinBackoff
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = DefaultMaxBackoff
+	}
+	if o.MaxBackoff < o.MinBackoff {
+		o.MaxBackoff = o.MinBackoff
+	}
+	return o
+}
+
+// APIError is returned when an embedding API responds with a non-200 status.
+type APIError struct {
+	API        string // Remote API name, e.g. "ollama"
+	StatusCode int
+	Message    string // Error message from the response body, if any
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s API error: status %d", e.API, e.StatusCode)
+	}
+	return fmt.Sprintf("%s API error: status %d: %s", e.API, e.StatusCode, e.Message)
+}
+
+// Is reports whether the error matches target. Responses saying the model
+// does not exist match ErrModelNotFound.
+func (e *APIError) Is(target error) bool {
+	return target == ErrModelNotFound && e.modelNotFound()
+}
+
+// modelNotFound reports whether the server rejected the request because it
+// does not have the model. Ollama answers "model \"x\" not found, try pulling
+// it first"; OpenAI-compatible servers say "the model `x` does not exist".
+func (e *APIError) modelNotFound() bool {
+	if e.StatusCode < 400 || e.StatusCode >= 500 {
+		return false
+	}
+	msg := strings.ToLower(e.Message)
+	return strings.Contains(msg, "model") &&
+		(strings.Contains(msg, "not found") || strings.Contains(msg, "does not exist"))
+}
+
+// temporary reports whether the request may succeed if retried.
+func (e *APIError) temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// httpClient posts JSON to an embedding API, retrying rate limits, server
+// errors and connection failures with exponential backoff and jitter.
+type httpClient struct {
+	http    *http.Client
+	opts    Options
+	apiName string // Identifies the remote API in error messages
+}
+
+// newHTTPClient creates an httpClient with the given options, applying defaults.
+func newHTTPClient(apiName string, opts Options) *httpClient {
+	opts = opts.withDefaults()
+	return &httpClient{
+		http:    &http.Client{Timeout: opts.Timeout},
+		opts:    opts,
+		apiName: apiName,
+	}
+}
+
+// postJSON sends body as JSON to url and decodes a successful response into out.
+func (c *httpClient) postJSON(ctx context.Context, url string, header http.Header, body, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		retryAfter, retry, err := c.do(ctx, url, header, payload, out)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= *c.opts.MaxRetries || ctx.Err() != nil {
+			if attempt > 0 {
+				return fmt.Errorf("after %d attempts: %w", attempt+1, err)
+			}
+			return err
+		}
+
+		timer := time.NewTimer(c.backoff(attempt, retryAfter))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
+
+// do makes a single request. It reports whether a failure is worth retrying
+// and any delay the server asked for via Retry-After.
+func (c *httpClient) do(ctx context.Context, url string, header http.Header, payload []byte, out any) (retryAfter time.Duration, retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, false, fmt.Errorf("create request: %w", err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		// Connection refused, reset and per-attempt timeouts are all transient
+		return 0, true, fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &APIError{
+			API:        c.apiName,
+			StatusCode: resp.StatusCode,
+			Message:    readErrorMessage(resp.Body),
+		}
+		return parseRetryAfter(resp.Header.Get("Retry-After")), apiErr.temporary() && !apiErr.modelNotFound(), apiErr
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return 0, false, fmt.Errorf("decode response: %w", err)
+	}
+
+	return 0, false, nil
+}
+
+// backoff returns the delay before retry number attempt+1: exponential from
+// MinBackoff, capped at MaxBackoff, with jitter over its upper half so that
+// concurrent workers do not retry in lockstep. A longer server-requested
+// delay takes precedence, still capped at MaxBackoff.
+func (c *httpClient) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	d := c.opts.MaxBackoff
+	if attempt < 32 {
+		d = min(c.opts.MinBackoff<<attempt, c.opts.MaxBackoff)
+	}
+	d = d/2 + rand.N(d/2+1)
+
+	if retryAfter > d {
+		d = min(retryAfter, c.opts.MaxBackoff)
+	}
+	return d
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds. HTTP dates
+// are rare from embedding servers and are ignored.
+func parseRetryAfter(value string) time.Duration {
+	secs, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || secs <= 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// readErrorMessage extracts a human-readable message from an error response.
+// It understands Ollama's {"error": "..."} and OpenAI's
+// {"error": {"message": "..."}} shapes and falls back to the raw body.
+func readErrorMessage(r io.Reader) string {
+	body, _ := io.ReadAll(io.LimitReader(r, maxErrorBody))
+
+	var parsed struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(body, &parsed) == nil && len(parsed.Error) > 0 {
+		var msg string
+		if json.Unmarshal(parsed.Error, &msg) == nil && msg != "" {
+			return msg
+		}
+		var obj struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(parsed.Error, &obj) == nil && obj.Message != "" {
+			return obj.Message
+		}
+	}
+
+	return strings.TrimSpace(string(body))
+}
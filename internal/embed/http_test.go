@@ -0,0 +1,172 @@
+package embed_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jamesainslie/grimoire/internal/embed"
+)
+
+// fastRetry keeps retry tests quick.
+var fastRetry = embed.Options{MinBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+
+func TestOllamaClient_Retry(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		failures  []int // Status codes returned before succeeding
+		opts      embed.Options
+		wantCalls int32
+		wantErr   bool
+	}{
+		{name: "server error then success", failures: []int{500}, opts: fastRetry, wantCalls: 2},
+		{name: "rate limited then success", failures: []int{429, 503}, opts: fastRetry, wantCalls: 3},
+		{name: "client error not retried", failures: []int{400}, opts: fastRetry, wantCalls: 1, wantErr: true},
+		{
+			name:      "retries exhausted",
+			failures:  []int{502, 502, 502},
+			opts:      embed.Options{MaxRetries: intPtr(2), MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond},
+			wantCalls: 3,
+			wantErr:   true,
+		},
+		{
+			name:      "retries disabled",
+			failures:  []int{500},
+			opts:      embed.Options{MaxRetries: intPtr(0)},
+			wantCalls: 1,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(calls.Add(1))
+				if n <= len(tt.failures) {
+					http.Error(w, "upstream unavailable", tt.failures[n-1])
+					return
+				}
+				json.NewEncoder(w).Encode(map[string]any{"embeddings": [][]float32{{0.1, 0.2}}})
+			}))
+			defer server.Close()
+
+			client := embed.NewOllama(server.URL, "snowflake-arctic-embed:l", tt.opts)
+			_, err := client.Embed(context.Background(), "test")
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Embed() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("Embed() made %d requests, want %d", got, tt.wantCalls)
+			}
+			if err != nil && !strings.Contains(err.Error(), "upstream unavailable") {
+				t.Errorf("Embed() error = %q, want response body included", err)
+			}
+		})
+	}
+}
+
+func TestOllamaClient_Timeout(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			// Simulate a slow model load on the first request
+			<-release
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"embeddings": [][]float32{{0.1, 0.2}}})
+	}))
+	defer server.Close()
+	defer close(release)
+
+	opts := fastRetry
+	opts.Timeout = 50 * time.Millisecond
+	client := embed.NewOllama(server.URL, "snowflake-arctic-embed:l", opts)
+
+	if _, err := client.Embed(context.Background(), "test"); err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("Embed() made %d requests, want 2", got)
+	}
+}
+
+func TestClient_ModelNotFound(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		body     string
+		newFn    func(url string) embed.Embedder
+		wantHint string
+	}{
+		{
+			name: "ollama",
+			body: `{"error":"model \"nomic-embed-text\" not found, try pulling it first"}`,
+			newFn: func(url string) embed.Embedder {
+				return embed.NewOllama(url, "nomic-embed-text", fastRetry)
+			},
+			wantHint: "ollama pull nomic-embed-text",
+		},
+		{
+			name: "openai",
+			body: `{"error":{"message":"The model 'nomic-embed-text' does not exist","code":"model_not_found"}}`,
+			newFn: func(url string) embed.Embedder {
+				return embed.NewOpenAI(url, "nomic-embed-text", "", fastRetry)
+			},
+			wantHint: `serves model "nomic-embed-text"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			_, err := tt.newFn(server.URL).Embed(context.Background(), "test")
+			if !errors.Is(err, embed.ErrModelNotFound) {
+				t.Fatalf("Embed() error = %v, want ErrModelNotFound", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantHint) {
+				t.Errorf("Embed() error = %q, want hint %q", err, tt.wantHint)
+			}
+			if strings.Contains(err.Error(), "{") {
+				t.Errorf("Embed() error = %q, want message extracted from JSON body", err)
+			}
+
+			var apiErr *embed.APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+				t.Errorf("Embed() error = %v, want APIError with status 404", err)
+			}
+			if got := calls.Load(); got != 1 {
+				t.Errorf("Embed() made %d requests, want 1", got)
+			}
+		})
+	}
+}
+
+// intPtr returns a pointer to n.
+func intPtr(n int) *int {
+	return &n
+}
@@ -0,0 +1,81 @@
+package embed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// OllamaClient generates embeddings using Ollama's native embed API.
+type OllamaClient struct {
+	baseURL string
+	model   string
+	client  *httpClient
+}
+
+// Ensure OllamaClient implements Embedder.
+var _ Embedder = (*OllamaClient)(nil)
+
+// NewOllama creates a new Ollama embedding client.
+// baseURL is the Ollama API URL (e.g., "http://localhost:11434").
+// model is the embedding model name (e.g., "snowflake-arctic-embed:l").
+// Zero opts fields take their defaults.
+func NewOllama(baseURL, model string, opts Options) *OllamaClient {
+	return &OllamaClient{
+		baseURL: baseURL,
+		model:   model,
+		client:  newHTTPClient("ollama", opts),
+	}
+}
+
+// ollamaRequest is the request body for the Ollama embed API.
+type ollamaRequest struct {
+	Model string `json:"model"`
+	Input any    `json:"input"` // string for single, []string for batch
+}
+
+// ollamaResponse is the response from the Ollama embed API.
+type ollamaResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+// Model returns the embedding model name.
+func (c *OllamaClient) Model() string {
+	return c.model
+}
+
+// Embed generates an embedding for a single text.
+func (c *OllamaClient) Embed(ctx context.Context, text string) ([]float32, error) {
+	embeddings, err := c.embed(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(embeddings) == 0 {
+		return nil, fmt.Errorf("no embeddings returned")
+	}
+
+	return embeddings[0], nil
+}
+
+// EmbedBatch generates embeddings for multiple texts in a single API call.
+func (c *OllamaClient) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	return c.embed(ctx, texts)
+}
+
+// embed sends an embedding request to the Ollama API.
+func (c *OllamaClient) embed(ctx context.Context, input any) ([][]float32, error) {
+	var resp ollamaResponse
+	err := c.client.postJSON(ctx, c.baseURL+"/api/embed", nil, ollamaRequest{
+		Model: c.model,
+		Input: input,
+	}, &resp)
+	if errors.Is(err, ErrModelNotFound) {
+		return nil, fmt.Errorf("%w (run `ollama pull %s`)", err, c.model)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Embeddings, nil
+}
@@ -0,0 +1,104 @@
+package embed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// OpenAIClient generates embeddings using an OpenAI-compatible /v1/embeddings
+// API, as served by llama.cpp, vLLM, LocalAI and OpenAI itself.
+type OpenAIClient struct {
+	baseURL string
+	model   string
+	apiKey  string
+	client  *httpClient
+}
+
+// Ensure OpenAIClient implements Embedder.
+var _ Embedder = (*OpenAIClient)(nil)
+
+// NewOpenAI creates a new OpenAI-compatible embedding client.
+// baseURL is the server URL, with or without the trailing "/v1"
+// (e.g., "http://localhost:8080"). apiKey may be empty for local servers.
+// Zero opts fields take their defaults.
+func NewOpenAI(baseURL, model, apiKey string, opts Options) *OpenAIClient {
+	return &OpenAIClient{
+		baseURL: strings.TrimSuffix(strings.TrimSuffix(baseURL, "/"), "/v1"),
+		model:   model,
+		apiKey:  apiKey,
+		client:  newHTTPClient("embeddings", opts),
+	}
+}
+
+// openAIRequest is the request body for the embeddings API.
+type openAIRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// openAIResponse is the response from the embeddings API.
+type openAIResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// Model returns the embedding model name.
+func (c *OpenAIClient) Model() string {
+	return c.model
+}
+
+// Embed generates an embedding for a single text.
+func (c *OpenAIClient) Embed(ctx context.Context, text string) ([]float32, error) {
+	embeddings, err := c.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(embeddings) == 0 {
+		return nil, fmt.Errorf("no embeddings returned")
+	}
+
+	return embeddings[0], nil
+}
+
+// EmbedBatch generates embeddings for multiple texts in a single API call.
+func (c *OpenAIClient) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	header := http.Header{}
+	if c.apiKey != "" {
+		header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	var resp openAIResponse
+	err := c.client.postJSON(ctx, c.baseURL+"/v1/embeddings", header, openAIRequest{
+		Model: c.model,
+		Input: texts,
+	}, &resp)
+	if errors.Is(err, ErrModelNotFound) {
+		return nil, fmt.Errorf("%w (check that %s serves model %q)", err, c.baseURL, c.model)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Data) != len(texts) {
+		return nil, fmt.Errorf("got %d embeddings for %d inputs", len(resp.Data), len(texts))
+	}
+
+	// The API does not guarantee response order, so sort by input index
+	sort.Slice(resp.Data, func(i, j int) bool {
+		return resp.Data[i].Index < resp.Data[j].Index
+	})
+
+	embeddings := make([][]float32, len(resp.Data))
+	for i, d := range resp.Data {
+		embeddings[i] = d.Embedding
+	}
+
+	return embeddings, nil
+}
@@ -0,0 +1,99 @@
+package embed_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jamesainslie/grimoire/internal/embed"
+)
+
+// embeddingsData mirrors one entry of the OpenAI embeddings response.
+type embeddingsData struct {
+	Index     int       `json:"index"`
+	Embedding []float32 `json:"embedding"`
+}
+
+func TestOpenAIClient_EmbedBatch(t *testing.T) {
+	t.Parallel()
+
+	// Create mock OpenAI-compatible server
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization = %q, want %q", got, "Bearer secret")
+		}
+
+		var req struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Model != "nomic-embed-text" {
+			t.Errorf("unexpected model: %s", req.Model)
+		}
+
+		// Return embeddings in reverse order to check index handling
+		var resp struct {
+			Data []embeddingsData `json:"data"`
+		}
+		for i := len(req.Input) - 1; i >= 0; i-- {
+			emb := make([]float32, 768)
+			emb[0] = float32(i + 1)
+			resp.Data = append(resp.Data, embeddingsData{Index: i, Embedding: emb})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	// A trailing /v1 in the base URL is accepted
+	client := embed.NewOpenAI(server.URL+"/v1", "nomic-embed-text", "secret", embed.Options{})
+
+	embeddings, err := client.EmbedBatch(context.Background(), []string{"one", "two", "three"})
+	if err != nil {
+		t.Fatalf("EmbedBatch() error = %v", err)
+	}
+	if len(embeddings) != 3 {
+		t.Fatalf("EmbedBatch() returned %d embeddings, want 3", len(embeddings))
+	}
+	for i, emb := range embeddings {
+		if emb[0] != float32(i+1) {
+			t.Errorf("EmbedBatch()[%d][0] = %v, want %v", i, emb[0], float32(i+1))
+		}
+	}
+
+	single, err := client.Embed(context.Background(), "one")
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	if len(single) != 768 {
+		t.Errorf("Embed() returned %d dimensions, want 768", len(single))
+	}
+}
+
+func TestOpenAIClient_EmbedServerError(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad request", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := embed.NewOpenAI(server.URL, "nomic-embed-text", "", embed.Options{})
+
+	_, err := client.Embed(context.Background(), "test")
+	if err == nil {
+		t.Error("Embed() expected error for server error, got nil")
+	}
+}
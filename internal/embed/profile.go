@@ -0,0 +1,253 @@
+package embed
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// bytesPerToken is a conservative estimate of input bytes per model token.
+// The chunker's estimate of 4 suits English prose, but code and non-Latin
+// text take fewer bytes per token, so a chunk sized by it can still exceed
+// the model's context and be truncated by the server.
+const bytesPerToken = 2
+
+// retrievalInstruction is the query prefix used by BGE-style retrieval models.
+const retrievalInstruction = "Represent this sentence for searching relevant passages: "
+
+// Profile describes how a model expects its inputs to be prepared.
+// Retrieval-tuned models are trained asymmetrically: queries and documents
+// carry different prefixes, and embedding a query as if it were a document
+// measurably hurts recall.
+type Profile struct {
+	QueryPrefix    string // Prepended to search queries
+	DocumentPrefix string // Prepended to indexed documents
+	MaxTokens      int    // Model context length; zero means unlimited. See EmbedQuery and EmbedDocuments
+	Normalize      bool   // Scale output vectors to unit length
+}
+
+// profiles maps model names to their profiles. A profile applies to every
+// model whose name starts with its key; the longest matching key wins.
+var profiles = map[string]Profile{
+	"snowflake-arctic-embed":  {QueryPrefix: retrievalInstruction, MaxTokens: 512, Normalize: true},
+	"snowflake-arctic-embed2": {QueryPrefix: "query: ", MaxTokens: 8192, Normalize: true},
+	"mxbai-embed-large":       {QueryPrefix: retrievalInstruction, MaxTokens: 512, Normalize: true},
+	"bge-":                    {QueryPrefix: retrievalInstruction, MaxTokens: 512, Normalize: true},
+	"bge-m3":                  {MaxTokens: 8192, Normalize: true},
+	"nomic-embed-text":        {QueryPrefix: "search_query: ", DocumentPrefix: "search_document: ", MaxTokens: 8192, Normalize: true},
+	"e5-":                     {QueryPrefix: "query: ", DocumentPrefix: "passage: ", MaxTokens: 512, Normalize: true},
+	"multilingual-e5-":        {QueryPrefix: "query: ", DocumentPrefix: "passage: ", MaxTokens: 512, Normalize: true},
+	"all-minilm":              {MaxTokens: 256, Normalize: true},
+}
+
+// ProfileFor returns the profile for model. Ollama tags ("model:tag") and
+// Hugging Face organisations ("org/model") are ignored when matching, and
+// unknown models get a profile that passes text through unchanged.
+func ProfileFor(model string) Profile {
+	name := strings.ToLower(model)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.Index(name, ":"); i >= 0 {
+		name = name[:i]
+	}
+
+	var best string
+	for key := range profiles {
+		if strings.HasPrefix(name, key) && len(key) > len(best) {
+			best = key
+		}
+	}
+	return profiles[best]
+}
+
+// Encoder embeds search queries and documents for a model, applying its
+// Profile on top of an Embedder.
+type Encoder struct {
+	embedder Embedder
+	profile  Profile
+}
+
+// NewEncoder creates an Encoder using the built-in profile for the
+// embedder's model.
+func NewEncoder(embedder Embedder) *Encoder {
+	return NewEncoderWithProfile(embedder, ProfileFor(embedder.Model()))
+}
+
+// NewEncoderWithProfile creates an Encoder with an explicit profile.
+func NewEncoderWithProfile(embedder Embedder, profile Profile) *Encoder {
+	return &Encoder{embedder: embedder, profile: profile}
+}
+
+// Model returns the embedding model name.
+func (e *Encoder) Model() string {
+	return e.embedder.Model()
+}
+
+// Profile returns the profile applied to inputs.
+func (e *Encoder) Profile() Profile {
+	return e.profile
+}
+
+// EmbedQuery embeds a search query, truncated to the model's context length.
+func (e *Encoder) EmbedQuery(ctx context.Context, query string) ([]float32, error) {
+	input := e.profile.QueryPrefix + query
+	if limit := e.inputLimit(); limit > 0 && len(input) > limit {
+		input = input[:runeBoundary(input, limit)]
+	}
+
+	embeddings, err := e.embedBatch(ctx, []string{input})
+	if err != nil {
+		return nil, err
+	}
+	return e.finish(embeddings[0]), nil
+}
+
+// EmbedDocuments embeds document texts for indexing, in input order.
+//
+// Servers either reject inputs longer than the model's context or silently
+// drop the tail. Instead, oversized texts are split into windows that fit,
+// embedded in the same batch as the rest, and averaged back into a single
+// vector weighted by window length, so every part of the text is represented.
+func (e *Encoder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	var (
+		inputs  []string
+		weights []float64
+		spans   = make([]int, len(texts)+1) // inputs[spans[i]:spans[i+1]] belong to texts[i]
+	)
+	for i, text := range texts {
+		spans[i] = len(inputs)
+		for _, part := range e.split(text) {
+			inputs = append(inputs, e.profile.DocumentPrefix+part)
+			weights = append(weights, float64(len(part)))
+		}
+	}
+	spans[len(texts)] = len(inputs)
+
+	embeddings, err := e.embedBatch(ctx, inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([][]float32, len(texts))
+	for i := range texts {
+		start, end := spans[i], spans[i+1]
+		if end-start == 1 {
+			result[i] = e.finish(embeddings[start])
+		} else {
+			result[i] = e.finish(average(embeddings[start:end], weights[start:end]))
+		}
+	}
+	return result, nil
+}
+
+// Oversize reports whether text is too long for the model's context as a
+// document and will be split by EmbedDocuments.
+func (e *Encoder) Oversize(text string) bool {
+	limit := e.inputLimit()
+	return limit > 0 && len(e.profile.DocumentPrefix)+len(text) > limit
+}
+
+// inputLimit returns the estimated maximum input length in bytes, or zero if
+// the model has no known limit.
+func (e *Encoder) inputLimit() int {
+	return e.profile.MaxTokens * bytesPerToken
+}
+
+// split divides a document into parts that fit the model's context once the
+// document prefix is added. Parts break at whitespace where possible.
+func (e *Encoder) split(text string) []string {
+	if !e.Oversize(text) {
+		return []string{text}
+	}
+
+	// Leave at least a small window even if the prefix alone is near the limit
+	budget := max(e.inputLimit()-len(e.profile.DocumentPrefix), bytesPerToken)
+
+	var parts []string
+	for len(text) > budget {
+		cut := runeBoundary(text, budget)
+		// Prefer breaking at whitespace in the second half of the window
+		if ws := strings.LastIndexAny(text[:cut], " \t\n"); ws > cut/2 {
+			cut = ws + 1
+		}
+		if cut == 0 {
+			cut = budget // Invalid UTF-8; split anywhere rather than loop
+		}
+		parts = append(parts, text[:cut])
+		text = text[cut:]
+	}
+	if strings.TrimSpace(text) != "" {
+		parts = append(parts, text)
+	}
+	return parts
+}
+
+// embedBatch embeds inputs, checking that one vector came back per input.
+func (e *Encoder) embedBatch(ctx context.Context, inputs []string) ([][]float32, error) {
+	embeddings, err := e.embedder.EmbedBatch(ctx, inputs)
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddings) != len(inputs) {
+		return nil, fmt.Errorf("got %d embeddings for %d inputs", len(embeddings), len(inputs))
+	}
+	return embeddings, nil
+}
+
+// finish applies the profile's output normalization.
+func (e *Encoder) finish(v []float32) []float32 {
+	if e.profile.Normalize {
+		return normalize(v)
+	}
+	return v
+}
+
+// runeBoundary returns the largest index no greater than n that does not
+// split a UTF-8 sequence in text. n must be less than len(text).
+func runeBoundary(text string, n int) int {
+	for n > 0 && !utf8.RuneStart(text[n]) {
+		n--
+	}
+	return n
+}
+
+// average returns the weighted mean of vecs.
+func average(vecs [][]float32, weights []float64) []float32 {
+	var total float64
+	sum := make([]float64, len(vecs[0]))
+	for i, v := range vecs {
+		for j, x := range v {
+			sum[j] += float64(x) * weights[i]
+		}
+		total += weights[i]
+	}
+
+	out := make([]float32, len(sum))
+	for j, x := range sum {
+		out[j] = float32(x / total)
+	}
+	return out
+}
+
+// normalize returns v scaled to unit length. It copies rather than scaling in
+// place because embedders such as CachedEmbedder may share result slices.
+// Zero vectors are returned as is.
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+
+	scale := 1 / math.Sqrt(sum)
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = float32(float64(x) * scale)
+	}
+	return out
+}
@@ -0,0 +1,145 @@
+package embed_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jamesainslie/grimoire/internal/chunk"
+	"github.com/jamesainslie/grimoire/internal/embed"
+)
+
+func TestProfileFor(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		model          string
+		wantQuery      string
+		wantDocument   string
+		wantMaxTokens  int
+		wantNormalized bool
+	}{
+		{model: "snowflake-arctic-embed:l", wantQuery: "Represent this sentence for searching relevant passages: ", wantMaxTokens: 512, wantNormalized: true},
+		{model: "Snowflake/snowflake-arctic-embed-m-v1.5", wantQuery: "Represent this sentence for searching relevant passages: ", wantMaxTokens: 512, wantNormalized: true},
+		{model: "snowflake-arctic-embed2:568m", wantQuery: "query: ", wantMaxTokens: 8192, wantNormalized: true},
+		{model: "nomic-embed-text:latest", wantQuery: "search_query: ", wantDocument: "search_document: ", wantMaxTokens: 8192, wantNormalized: true},
+		{model: "BAAI/bge-m3", wantMaxTokens: 8192, wantNormalized: true},
+		{model: "some-custom-model"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			t.Parallel()
+
+			got := embed.ProfileFor(tt.model)
+			if got.QueryPrefix != tt.wantQuery || got.DocumentPrefix != tt.wantDocument {
+				t.Errorf("ProfileFor() prefixes = %q, %q; want %q, %q", got.QueryPrefix, got.DocumentPrefix, tt.wantQuery, tt.wantDocument)
+			}
+			if got.MaxTokens != tt.wantMaxTokens || got.Normalize != tt.wantNormalized {
+				t.Errorf("ProfileFor() = %+v, want MaxTokens %d, Normalize %v", got, tt.wantMaxTokens, tt.wantNormalized)
+			}
+		})
+	}
+}
+
+func TestEncoder_EmbedQuery(t *testing.T) {
+	t.Parallel()
+
+	inner := &countingEmbedder{}
+	encoder := embed.NewEncoderWithProfile(inner, embed.Profile{
+		QueryPrefix:    "q: ",
+		DocumentPrefix: "d: ",
+		MaxTokens:      8, // 16 bytes
+		Normalize:      true,
+	})
+
+	vec, err := encoder.EmbedQuery(context.Background(), "goroutine leaks")
+	if err != nil {
+		t.Fatalf("EmbedQuery() error = %v", err)
+	}
+	if len(vec) != 1 || vec[0] != 1 {
+		t.Errorf("EmbedQuery() = %v, want normalized [1]", vec)
+	}
+	if want := []string{"q: goroutine lea"}; fmt.Sprint(inner.sent) != fmt.Sprint(want) {
+		t.Errorf("provider received %q, want truncated %q", inner.sent, want)
+	}
+}
+
+func TestEncoder_EmbedDocuments(t *testing.T) {
+	t.Parallel()
+
+	inner := &countingEmbedder{}
+	encoder := embed.NewEncoderWithProfile(inner, embed.Profile{
+		QueryPrefix:    "q: ",
+		DocumentPrefix: "d: ",
+		MaxTokens:      8, // 16 bytes, 13 after the prefix
+	})
+
+	long := "héllo wörld, a longer document"
+	if encoder.Oversize("short") || !encoder.Oversize(long) {
+		t.Errorf("Oversize() = %v, %v; want false, true", encoder.Oversize("short"), encoder.Oversize(long))
+	}
+
+	got, err := encoder.EmbedDocuments(context.Background(), []string{"short", long})
+	if err != nil {
+		t.Fatalf("EmbedDocuments() error = %v", err)
+	}
+
+	// The long document is split at whitespace and rune boundaries
+	want := []string{"d: short", "d: héllo wörld", "d: , a longer ", "d: document"}
+	if fmt.Sprint(inner.sent) != fmt.Sprint(want) {
+		t.Errorf("provider received %q, want %q", inner.sent, want)
+	}
+	if strings.Join([]string{"héllo wörld", ", a longer ", "document"}, "") != long {
+		t.Fatal("test parts do not reassemble the document")
+	}
+
+	// countingEmbedder embeds each input as its length, so the split
+	// document's vector is the mean of 16, 14 and 11 weighted by 13, 11 and 8
+	if len(got) != 2 || got[0][0] != 8 {
+		t.Fatalf("EmbedDocuments() = %v, want 2 vectors starting with [8]", got)
+	}
+	if wantAvg := float32(16*13+14*11+11*8) / 32; got[1][0] != wantAvg {
+		t.Errorf("EmbedDocuments()[1] = %v, want [%v]", got[1], wantAvg)
+	}
+}
+
+func TestEncoder_EmbedDocuments_DenseChunk(t *testing.T) {
+	t.Parallel()
+
+	inner := &countingEmbedder{}
+	encoder := embed.NewEncoderWithProfile(inner, embed.Profile{MaxTokens: 512})
+
+	// Code fits a 512-token chunk by the chunker's estimate, but tokenizes
+	// to far more than 512 tokens, so it must not reach the model whole
+	code := strings.Repeat("if err != nil {\n\treturn fmt.Errorf(\"open %s: %w\", name, err)\n}\n", 25)
+	if tokens := chunk.EstimateTokens(code); tokens > 512 {
+		t.Fatalf("EstimateTokens() = %d, want a chunk within 512", tokens)
+	}
+	if !encoder.Oversize(code) {
+		t.Error("Oversize() = false, want true")
+	}
+
+	got, err := encoder.EmbedDocuments(context.Background(), []string{code})
+	if err != nil {
+		t.Fatalf("EmbedDocuments() error = %v", err)
+	}
+	if len(inner.sent) < 2 {
+		t.Fatalf("provider received %d inputs, want the chunk split", len(inner.sent))
+	}
+
+	// countingEmbedder embeds each input as its length, so the vector is the
+	// mean of the part lengths weighted by themselves
+	var sum, total float64
+	for _, part := range inner.sent {
+		if len(part) > 1024 {
+			t.Errorf("provider received %d bytes, want at most 1024", len(part))
+		}
+		sum += float64(len(part) * len(part))
+		total += float64(len(part))
+	}
+	if len(got) != 1 || got[0][0] != float32(sum/total) {
+		t.Errorf("EmbedDocuments() = %v, want [%v]", got, float32(sum/total))
+	}
+}
@@ -0,0 +1,345 @@
+package ingest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/jamesainslie/grimoire/internal/chunk"
+	"github.com/jamesainslie/grimoire/internal/embed"
+	"github.com/jamesainslie/grimoire/internal/parse"
+	"github.com/jamesainslie/grimoire/internal/store"
+)
+
+// Outcome describes what happened to a single file during ingest.
+type Outcome int
+
+const (
+	// Unchanged means the file's content hash matched the indexed version.
+	Unchanged Outcome = iota
+	// Added means the file was indexed for the first time.
+	Added
+	// Updated means the file changed and was re-indexed.
+	Updated
+)
+
+// String returns a human-readable name for the outcome.
+func (o Outcome) String() string {
+	switch o {
+	case Added:
+		return "added"
+	case Updated:
+		return "updated"
+	default:
+		return "unchanged"
+	}
+}
+
+// Report tallies file outcomes across an ingest run.
+type Report struct {
+	Added     int
+	Updated   int
+	Unchanged int
+	Removed   int
+	Failed    int
+	Oversize  int // Chunks too long for the embedding model, embedded in parts
+}
+
+// Record adds a single file outcome to the report.
+func (r *Report) Record(o Outcome) {
+	switch o {
+	case Added:
+		r.Added++
+	case Updated:
+		r.Updated++
+	default:
+		r.Unchanged++
+	}
+}
+
+// Add merges another report into this one.
+func (r *Report) Add(other Report) {
+	r.Added += other.Added
+	r.Updated += other.Updated
+	r.Unchanged += other.Unchanged
+	r.Removed += other.Removed
+	r.Failed += other.Failed
+	r.Oversize += other.Oversize
+}
+
+// FileResult describes the result of ingesting a single file.
+type FileResult struct {
+	Outcome  Outcome
+	Chunks   int // Number of chunks written; zero when unchanged
+	Oversize int // Chunks exceeding the model's context, embedded in parts
+}
+
+// Default embedding settings used when Options fields are zero.
+const (
+	DefaultBatchSize   = 32
+	DefaultConcurrency = 4
+)
+
+// Options tunes how an Ingester talks to the embedding provider.
+type Options struct {
+	BatchSize   int // Chunks sent per embedding request
+	Concurrency int // Maximum embedding requests in flight, and files processed in parallel
+}
+
+// Ingester parses, chunks and embeds files into the store. It is safe for
+// concurrent use.
+type Ingester struct {
+	store     *store.Store
+	encoder   *embed.Encoder
+	chunker   *chunk.Chunker
+	batchSize int
+
+	// sem bounds the number of embedding requests in flight across all files.
+	sem chan struct{}
+	// writeMu serializes store writes; SQLite allows a single writer.
+	writeMu sync.Mutex
+}
+
+// NewIngester creates a new ingester. Chunks are embedded as documents using
+// the built-in profile for the embedder's model.
+func NewIngester(s *store.Store, embedder embed.Embedder, chunker *chunk.Chunker, opts Options) *Ingester {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultConcurrency
+	}
+
+	return &Ingester{
+		store:     s,
+		encoder:   embed.NewEncoder(embedder),
+		chunker:   chunker,
+		batchSize: opts.BatchSize,
+		sem:       make(chan struct{}, opts.Concurrency),
+	}
+}
+
+// FileEvent reports the result of ingesting one file to an IngestFiles callback.
+type FileEvent struct {
+	Path   string
+	Result *FileResult // Nil if Err is set
+	Err    error
+}
+
+// IngestFiles ingests files under root for the given source, processing up to
+// Options.Concurrency files in parallel. onFile, if non-nil, is called once per
+// file; calls are serialized but arrive in completion order.
+func (in *Ingester) IngestFiles(ctx context.Context, sourceID int64, root string, files []string, onFile func(FileEvent)) Report {
+	var (
+		report Report
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+	)
+
+	paths := make(chan string)
+	for range cap(in.sem) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				event := FileEvent{Path: path}
+				content, err := os.ReadFile(filepath.Join(root, path))
+				if err != nil {
+					event.Err = fmt.Errorf("read: %w", err)
+				} else {
+					event.Result, event.Err = in.IngestFile(ctx, sourceID, path, content)
+				}
+
+				mu.Lock()
+				if event.Err != nil {
+					report.Failed++
+				} else {
+					report.Record(event.Result.Outcome)
+					report.Oversize += event.Result.Oversize
+				}
+				if onFile != nil {
+					onFile(event)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, path := range files {
+		paths <- path
+	}
+	close(paths)
+	wg.Wait()
+
+	return report
+}
+
+// ContentHash returns the hex-encoded SHA-256 hash of content.
+func ContentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// IngestFile indexes a single file for the given source. Files whose content
+// hash matches the indexed version are skipped; changed files have their
+// chunks, FTS entries and embeddings replaced atomically.
+func (in *Ingester) IngestFile(ctx context.Context, sourceID int64, path string, content []byte) (*FileResult, error) {
+	hash := ContentHash(content)
+
+	outcome := Added
+	existing, err := in.store.GetDocumentByPath(ctx, sourceID, path)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+	case err != nil:
+		return nil, fmt.Errorf("get document: %w", err)
+	case existing.ContentHash == hash:
+		return &FileResult{Outcome: Unchanged}, nil
+	default:
+		outcome = Updated
+	}
+
+	doc, err := parse.Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("parse: %w", err)
+	}
+
+	chunks, err := in.chunker.Chunk(doc)
+	if err != nil {
+		return nil, fmt.Errorf("chunk: %w", err)
+	}
+
+	newChunks := make([]store.NewChunk, len(chunks))
+	var toEmbed []int // Indexes of chunks that need an embedding
+	var oversize int
+	for i, c := range chunks {
+		newChunks[i] = store.NewChunk{
+			ParentIndex: c.ParentIndex,
+			Level:       c.Level,
+			Title:       c.Title,
+			Content:     c.Content,
+			TokenCount:  c.TokenCount,
+			Breadcrumbs: c.Breadcrumbs,
+			Anchor:      c.Anchor,
+		}
+
+		// Skip embedding chunks with too little content to be meaningful
+		if len(strings.TrimSpace(c.Content)) >= 10 {
+			toEmbed = append(toEmbed, i)
+			if in.encoder.Oversize(c.Content) {
+				oversize++
+			}
+		}
+	}
+
+	if err := in.embedChunks(ctx, newChunks, toEmbed); err != nil {
+		return nil, err
+	}
+
+	headings := make([]store.Heading, len(doc.Headings))
+	for i, h := range doc.Headings {
+		headings[i] = store.Heading{Level: h.Level, Text: h.Text, Anchor: h.Anchor}
+	}
+
+	in.writeMu.Lock()
+	defer in.writeMu.Unlock()
+
+	// The first stored vector fixes the database's model and dimension
+	if len(toEmbed) > 0 {
+		dims := len(newChunks[toEmbed[0]].Embedding)
+		if err := in.store.RegisterEmbeddingModel(ctx, in.encoder.Model(), dims); err != nil {
+			return nil, fmt.Errorf("register embedding model: %w", err)
+		}
+	}
+
+	_, err = in.store.ReplaceDocument(ctx, &store.Document{
+		SourceID:    sourceID,
+		Path:        path,
+		Title:       doc.Title,
+		ContentHash: hash,
+		Headings:    headings,
+	}, newChunks)
+	if err != nil {
+		return nil, fmt.Errorf("store document: %w", err)
+	}
+
+	return &FileResult{Outcome: outcome, Chunks: len(newChunks), Oversize: oversize}, nil
+}
+
+// embedChunks fills in embeddings for the chunks at the given indexes, sending
+// batches of up to batchSize texts concurrently, bounded by the ingester's
+// request limit.
+func (in *Ingester) embedChunks(ctx context.Context, chunks []store.NewChunk, indexes []int) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+
+	for start := 0; start < len(indexes); start += in.batchSize {
+		batch := indexes[start:min(start+in.batchSize, len(indexes))]
+
+		select {
+		case in.sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			if firstErr != nil {
+				return firstErr
+			}
+			return ctx.Err()
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-in.sem }()
+
+			texts := make([]string, len(batch))
+			for i, idx := range batch {
+				texts[i] = chunks[idx].Content
+			}
+
+			embeddings, err := in.encoder.EmbedDocuments(ctx, texts)
+			if err == nil && len(embeddings) != len(texts) {
+				err = fmt.Errorf("got %d embeddings for %d chunks", len(embeddings), len(texts))
+			}
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = fmt.Errorf("embed chunks %d-%d: %w", batch[0], batch[len(batch)-1], err)
+					cancel()
+				})
+				return
+			}
+
+			// Each goroutine writes a disjoint set of indexes
+			for i, idx := range batch {
+				chunks[idx].Embedding = embeddings[i]
+			}
+		}()
+	}
+
+	wg.Wait()
+	return firstErr
+}
+
+// Prune removes documents of a source that are no longer among the files
+// listed for it, e.g. because they were deleted or renamed upstream or their
+// path pattern was dropped from the language pack. It returns the removed paths.
+func (in *Ingester) Prune(ctx context.Context, sourceID int64, files []string) ([]string, error) {
+	in.writeMu.Lock()
+	defer in.writeMu.Unlock()
+
+	removed, err := in.store.PruneDocuments(ctx, sourceID, files)
+	if err != nil {
+		return nil, fmt.Errorf("prune documents: %w", err)
+	}
+	return removed, nil
+}
@@ -0,0 +1,328 @@
+package ingest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jamesainslie/grimoire/internal/chunk"
+	"github.com/jamesainslie/grimoire/internal/ingest"
+	"github.com/jamesainslie/grimoire/internal/store"
+)
+
+// fakeEmbedder returns a fixed embedding and counts texts embedded and batch
+// requests made. It is safe for concurrent use.
+type fakeEmbedder struct {
+	model string // Defaults to "fake", which has no embedding profile
+
+	mu      sync.Mutex
+	calls   int // Texts embedded
+	batches int // EmbedBatch requests
+}
+
+func (f *fakeEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	f.mu.Lock()
+	f.calls++
+	f.mu.Unlock()
+
+	v := make([]float32, 1024)
+	v[0] = 1.0
+	return v, nil
+}
+
+func (f *fakeEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	f.mu.Lock()
+	f.batches++
+	f.mu.Unlock()
+
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embeddings[i], _ = f.Embed(ctx, text)
+	}
+	return embeddings, nil
+}
+
+func (f *fakeEmbedder) Model() string {
+	if f.model == "" {
+		return "fake"
+	}
+	return f.model
+}
+
+const testDoc = `# Error Handling
+
+Errors are values. Always check returned errors and wrap them with context
+using fmt.Errorf and the %w verb so callers can inspect them.
+
+## Sentinel Errors
+
+Declare sentinel errors with errors.New at package level and compare them
+with errors.Is rather than by string matching.
+`
+
+func TestIngester_IngestFile(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s, sourceID := newTestSource(t)
+	embedder := &fakeEmbedder{}
+	ingester := ingest.NewIngester(s, embedder, chunk.NewChunker(512), ingest.Options{})
+
+	// First ingest adds the document
+	result, err := ingester.IngestFile(ctx, sourceID, "errors.md", []byte(testDoc))
+	if err != nil {
+		t.Fatalf("IngestFile() error = %v", err)
+	}
+	if result.Outcome != ingest.Added {
+		t.Errorf("IngestFile() Outcome = %v, want added", result.Outcome)
+	}
+	if result.Chunks == 0 {
+		t.Error("IngestFile() wrote no chunks")
+	}
+	firstChunks := result.Chunks
+	firstCalls := embedder.calls
+
+	// Same content is skipped without re-embedding
+	result, err = ingester.IngestFile(ctx, sourceID, "errors.md", []byte(testDoc))
+	if err != nil {
+		t.Fatalf("IngestFile(unchanged) error = %v", err)
+	}
+	if result.Outcome != ingest.Unchanged {
+		t.Errorf("IngestFile(unchanged) Outcome = %v, want unchanged", result.Outcome)
+	}
+	if embedder.calls != firstCalls {
+		t.Errorf("IngestFile(unchanged) made %d embed calls, want 0", embedder.calls-firstCalls)
+	}
+
+	// Changed content replaces the old chunks
+	changed := testDoc + "\n## Panics\n\nDo not panic in library code; return an error instead.\n"
+	result, err = ingester.IngestFile(ctx, sourceID, "errors.md", []byte(changed))
+	if err != nil {
+		t.Fatalf("IngestFile(changed) error = %v", err)
+	}
+	if result.Outcome != ingest.Updated {
+		t.Errorf("IngestFile(changed) Outcome = %v, want updated", result.Outcome)
+	}
+
+	stats, err := s.GetStats(ctx)
+	if err != nil {
+		t.Fatalf("GetStats() error = %v", err)
+	}
+	if stats.Documents != 1 {
+		t.Errorf("Documents = %d, want 1", stats.Documents)
+	}
+	if stats.Chunks != int64(result.Chunks) {
+		t.Errorf("Chunks = %d, want %d (old chunks not replaced)", stats.Chunks, result.Chunks)
+	}
+	if stats.Embeddings != stats.Chunks {
+		t.Errorf("Embeddings = %d, want %d", stats.Embeddings, stats.Chunks)
+	}
+	if result.Chunks <= firstChunks {
+		t.Errorf("updated document has %d chunks, want more than %d", result.Chunks, firstChunks)
+	}
+
+	doc, err := s.GetDocumentByPath(ctx, sourceID, "errors.md")
+	if err != nil {
+		t.Fatalf("GetDocumentByPath() error = %v", err)
+	}
+	if doc.ContentHash != ingest.ContentHash([]byte(changed)) {
+		t.Errorf("ContentHash = %q, want hash of updated content", doc.ContentHash)
+	}
+	if doc.FetchedAt.IsZero() {
+		t.Error("FetchedAt is zero")
+	}
+}
+
+func TestIngester_Prune(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s, sourceID := newTestSource(t)
+	ingester := ingest.NewIngester(s, &fakeEmbedder{}, chunk.NewChunker(512), ingest.Options{})
+
+	for _, path := range []string{"errors.md", "old.md"} {
+		if _, err := ingester.IngestFile(ctx, sourceID, path, []byte(testDoc)); err != nil {
+			t.Fatalf("IngestFile(%s) error = %v", path, err)
+		}
+	}
+
+	removed, err := ingester.Prune(ctx, sourceID, []string{"errors.md"})
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if len(removed) != 1 || removed[0] != "old.md" {
+		t.Errorf("Prune() removed = %v, want [old.md]", removed)
+	}
+
+	if _, err := s.GetDocumentByPath(ctx, sourceID, "old.md"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetDocumentByPath(old.md) error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetDocumentByPath(ctx, sourceID, "errors.md"); err != nil {
+		t.Errorf("GetDocumentByPath(errors.md) error = %v", err)
+	}
+}
+
+func TestIngester_IngestFile_Batches(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		batchSize int
+	}{
+		{name: "one per batch", batchSize: 1},
+		{name: "two per batch", batchSize: 2},
+		{name: "single batch", batchSize: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			s, sourceID := newTestSource(t)
+			embedder := &fakeEmbedder{}
+			ingester := ingest.NewIngester(s, embedder, chunk.NewChunker(512), ingest.Options{
+				BatchSize:   tt.batchSize,
+				Concurrency: 2,
+			})
+
+			result, err := ingester.IngestFile(ctx, sourceID, "errors.md", []byte(testDoc))
+			if err != nil {
+				t.Fatalf("IngestFile() error = %v", err)
+			}
+
+			wantBatches := (embedder.calls + tt.batchSize - 1) / tt.batchSize
+			if embedder.batches != wantBatches {
+				t.Errorf("EmbedBatch() called %d times for %d texts, want %d", embedder.batches, embedder.calls, wantBatches)
+			}
+
+			stats, _ := s.GetStats(ctx)
+			if stats.Embeddings != int64(embedder.calls) || stats.Chunks != int64(result.Chunks) {
+				t.Errorf("stats = %d chunks, %d embeddings; want %d, %d",
+					stats.Chunks, stats.Embeddings, result.Chunks, embedder.calls)
+			}
+		})
+	}
+}
+
+func TestIngester_IngestFile_Oversize(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s, sourceID := newTestSource(t)
+	// all-minilm has a 256-token context, about 1 KB of text
+	embedder := &fakeEmbedder{model: "all-minilm"}
+	ingester := ingest.NewIngester(s, embedder, chunk.NewChunker(512), ingest.Options{})
+
+	// A single paragraph the chunker keeps whole but the model cannot take
+	long := "# Generics\n\n" + strings.Repeat("Type parameters let functions work over many types. ", 30) + "\n"
+	result, err := ingester.IngestFile(ctx, sourceID, "generics.md", []byte(long))
+	if err != nil {
+		t.Fatalf("IngestFile() error = %v", err)
+	}
+	if result.Oversize == 0 {
+		t.Error("IngestFile() Oversize = 0, want oversize chunks counted")
+	}
+
+	// Parts are averaged back into a single vector per chunk
+	stats, _ := s.GetStats(ctx)
+	if stats.Embeddings == 0 || int64(embedder.calls) <= stats.Embeddings {
+		t.Errorf("embedded %d texts for %d stored vectors, want oversize chunks split into parts", embedder.calls, stats.Embeddings)
+	}
+}
+
+func TestIngester_IngestFiles(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s, sourceID := newTestSource(t)
+	ingester := ingest.NewIngester(s, &fakeEmbedder{}, chunk.NewChunker(512), ingest.Options{
+		BatchSize:   2,
+		Concurrency: 4,
+	})
+
+	root := t.TempDir()
+	var files []string
+	for i := range 8 {
+		path := fmt.Sprintf("doc%d.md", i)
+		if err := os.WriteFile(filepath.Join(root, path), []byte(testDoc), 0o644); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+		files = append(files, path)
+	}
+	files = append(files, "missing.md")
+
+	var seen []string
+	report := ingester.IngestFiles(ctx, sourceID, root, files, func(e ingest.FileEvent) {
+		seen = append(seen, e.Path)
+	})
+
+	want := ingest.Report{Added: 8, Failed: 1}
+	if report != want {
+		t.Errorf("IngestFiles() report = %+v, want %+v", report, want)
+	}
+	sort.Strings(seen)
+	sort.Strings(files)
+	if fmt.Sprint(seen) != fmt.Sprint(files) {
+		t.Errorf("onFile saw %v, want %v", seen, files)
+	}
+
+	stats, _ := s.GetStats(ctx)
+	if stats.Documents != 8 {
+		t.Errorf("Documents = %d, want 8", stats.Documents)
+	}
+	if stats.Embeddings != stats.Chunks {
+		t.Errorf("Embeddings = %d, want %d", stats.Embeddings, stats.Chunks)
+	}
+
+	// A second run finds everything unchanged
+	report = ingester.IngestFiles(ctx, sourceID, root, files[:8], nil)
+	if report != (ingest.Report{Unchanged: 8}) {
+		t.Errorf("IngestFiles(unchanged) report = %+v, want 8 unchanged", report)
+	}
+}
+
+func TestReport_Record(t *testing.T) {
+	t.Parallel()
+
+	var r ingest.Report
+	r.Record(ingest.Added)
+	r.Record(ingest.Updated)
+	r.Record(ingest.Unchanged)
+	r.Record(ingest.Unchanged)
+	r.Add(ingest.Report{Added: 1, Removed: 3, Failed: 2, Oversize: 4})
+
+	want := ingest.Report{Added: 2, Updated: 1, Unchanged: 2, Removed: 3, Failed: 2, Oversize: 4}
+	if r != want {
+		t.Errorf("Report = %+v, want %+v", r, want)
+	}
+}
+
+// newTestSource creates an in-memory store with a single source.
+func newTestSource(t *testing.T) (*store.Store, int64) {
+	t.Helper()
+
+	s, err := store.New(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create test store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	ctx := context.Background()
+	lang, err := s.CreateLanguage(ctx, "go", "Go")
+	if err != nil {
+		t.Fatalf("CreateLanguage() error = %v", err)
+	}
+	src, err := s.CreateSource(ctx, lang.ID, "go-wiki", "git", "https://github.com/golang/wiki")
+	if err != nil {
+		t.Fatalf("CreateSource() error = %v", err)
+	}
+
+	return s, src.ID
+}
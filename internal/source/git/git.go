@@ -67,6 +67,20 @@ func (f *Fetcher) Fetch(ctx context.Context, url string) (string, error) {
 	return localPath, nil
 }
 
+// Revision returns the commit hash checked out in a repository fetched to
+// localPath.
+func (f *Fetcher) Revision(localPath string) (string, error) {
+	repo, err := git.PlainOpen(localPath)
+	if err != nil {
+		return "", fmt.Errorf("open repo: %w", err)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", fmt.Errorf("resolve HEAD: %w", err)
+	}
+	return head.Hash().String(), nil
+}
+
 // ListFiles returns files matching the given glob patterns.
 func (f *Fetcher) ListFiles(rootPath string, patterns []string) ([]string, error) {
 	var matches []string
@@ -5,7 +5,10 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/jamesainslie/grimoire/internal/source/git"
 )
 
@@ -136,6 +139,45 @@ func TestFetcher_ListFiles(t *testing.T) {
 	}
 }
 
+func TestFetcher_Revision(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	repo, err := gogit.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("PlainInit() error = %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Test\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Worktree() error = %v", err)
+	}
+	if _, err := wt.Add("README.md"); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	commit, err := wt.Commit("Initial commit", &gogit.CommitOptions{
+		Author: &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	fetcher := git.NewFetcher(t.TempDir())
+	got, err := fetcher.Revision(dir)
+	if err != nil {
+		t.Fatalf("Revision() error = %v", err)
+	}
+	if got != commit.String() {
+		t.Errorf("Revision() = %q, want %q", got, commit.String())
+	}
+
+	if _, err := fetcher.Revision(t.TempDir()); err == nil {
+		t.Error("Revision() of a directory without a repository succeeded, want error")
+	}
+}
+
 func TestFetcher_URLToPath(t *testing.T) {
 	t.Parallel()
 
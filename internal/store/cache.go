@@ -0,0 +1,296 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// lookupBatchSize bounds the number of hashes bound into a single lookup
+// query, keeping well under SQLite's host parameter limit.
+const lookupBatchSize = 500
+
+// EmbeddingCache is a persistent cache of embeddings keyed by model and the
+// SHA-256 hash of the embedded text. It lives in its own SQLite file so that
+// it survives deleting or rebuilding the knowledge base, and is safe to share
+// between processes.
+type EmbeddingCache struct {
+	db *sql.DB
+}
+
+// CacheStats describes the contents and effectiveness of an EmbeddingCache.
+type CacheStats struct {
+	Entries int64
+	Models  int64
+	Bytes   int64 // Size of the cache database file
+	Hits    int64 // Lookups served from the cache since it was created
+	Misses  int64 // Lookups that had to go to the embedding provider
+}
+
+// CachePruneOptions selects cache entries to remove. An entry is removed if
+// it matches either condition.
+type CachePruneOptions struct {
+	UnusedSince time.Time // Remove entries not used since this time; zero keeps all
+	KeepModel   string    // Remove entries for every other model; empty keeps all
+}
+
+// OpenEmbeddingCache opens the cache database at path, creating it if needed.
+// Use ":memory:" for a throwaway cache.
+func OpenEmbeddingCache(path string) (*EmbeddingCache, error) {
+	dsn := path
+	if path != ":memory:" {
+		// WAL and a busy timeout let concurrent ingest workers and a running
+		// MCP server share the cache without "database is locked" errors.
+		// Lookup reads and then writes, so its transaction must take the
+		// write lock up front: upgrading a WAL read transaction fails at
+		// once, without waiting on the busy timeout.
+		dsn += "?_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate"
+	}
+
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open embedding cache: %w", err)
+	}
+	if path == ":memory:" {
+		// See Open; a second connection would see an empty database
+		db.SetMaxOpenConns(1)
+	}
+
+	// The cache is disposable, so its schema is created in place rather than
+	// migrated; an incompatible future layout can simply start a new file.
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS embeddings (
+			model TEXT NOT NULL,
+			text_hash TEXT NOT NULL,
+			embedding BLOB NOT NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			last_used_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (model, text_hash)
+		) WITHOUT ROWID;
+		CREATE TABLE IF NOT EXISTS counters (
+			name TEXT PRIMARY KEY,
+			value INTEGER NOT NULL
+		);
+	`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create embedding cache schema: %w", err)
+	}
+
+	return &EmbeddingCache{db: db}, nil
+}
+
+// Close closes the cache database.
+func (c *EmbeddingCache) Close() error {
+	return c.db.Close()
+}
+
+// Lookup returns the cached embeddings for model among the given text hashes,
+// keyed by hash. Missing hashes are absent from the result. Each lookup is
+// counted as a hit or miss in the cache statistics.
+func (c *EmbeddingCache) Lookup(ctx context.Context, model string, hashes []string) (map[string][]float32, error) {
+	found := make(map[string][]float32, len(hashes))
+	if len(hashes) == 0 {
+		return found, nil
+	}
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for start := 0; start < len(hashes); start += lookupBatchSize {
+		batch := hashes[start:min(start+lookupBatchSize, len(hashes))]
+		marks := placeholders(len(batch))
+		args := make([]any, 0, len(batch)+1)
+		args = append(args, model)
+		for _, h := range batch {
+			args = append(args, h)
+		}
+
+		rows, err := tx.QueryContext(ctx,
+			"SELECT text_hash, embedding FROM embeddings WHERE model = ? AND text_hash IN ("+marks+")",
+			args...,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("query embeddings: %w", err)
+		}
+		for rows.Next() {
+			var hash string
+			var blob []byte
+			if err := rows.Scan(&hash, &blob); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("scan embedding: %w", err)
+			}
+			found[hash] = bytesToFloat32(blob)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("iterate embeddings: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx,
+			"UPDATE embeddings SET last_used_at = CURRENT_TIMESTAMP WHERE model = ? AND text_hash IN ("+marks+")",
+			args...,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("touch embeddings: %w", err)
+		}
+	}
+
+	var hits int64
+	for _, h := range hashes {
+		if _, ok := found[h]; ok {
+			hits++
+		}
+	}
+	if err := addCounter(ctx, tx, "hits", hits); err != nil {
+		return nil, err
+	}
+	if err := addCounter(ctx, tx, "misses", int64(len(hashes))-hits); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+	return found, nil
+}
+
+// Store caches embeddings for model, keyed by text hash.
+func (c *EmbeddingCache) Store(ctx context.Context, model string, entries map[string][]float32) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx,
+		"INSERT OR REPLACE INTO embeddings (model, text_hash, embedding) VALUES (?, ?, ?)",
+	)
+	if err != nil {
+		return fmt.Errorf("prepare insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for hash, embedding := range entries {
+		if _, err := stmt.ExecContext(ctx, model, hash, float32ToBytes(embedding)); err != nil {
+			return fmt.Errorf("insert embedding: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Stats returns the number of cached embeddings and the hit/miss counters.
+func (c *EmbeddingCache) Stats(ctx context.Context) (*CacheStats, error) {
+	stats := &CacheStats{}
+
+	err := c.db.QueryRowContext(ctx,
+		"SELECT COUNT(*), COUNT(DISTINCT model) FROM embeddings",
+	).Scan(&stats.Entries, &stats.Models)
+	if err != nil {
+		return nil, fmt.Errorf("count embeddings: %w", err)
+	}
+
+	err = c.db.QueryRowContext(ctx,
+		"SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()",
+	).Scan(&stats.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("get cache size: %w", err)
+	}
+
+	rows, err := c.db.QueryContext(ctx, "SELECT name, value FROM counters")
+	if err != nil {
+		return nil, fmt.Errorf("query counters: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var value int64
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, fmt.Errorf("scan counter: %w", err)
+		}
+		switch name {
+		case "hits":
+			stats.Hits = value
+		case "misses":
+			stats.Misses = value
+		}
+	}
+
+	return stats, rows.Err()
+}
+
+// Prune removes cache entries selected by opts, reclaims the freed space and
+// returns the number of entries removed.
+func (c *EmbeddingCache) Prune(ctx context.Context, opts CachePruneOptions) (int64, error) {
+	var conds []string
+	var args []any
+	if !opts.UnusedSince.IsZero() {
+		conds = append(conds, "last_used_at < ?")
+		args = append(args, opts.UnusedSince.UTC().Format(time.DateTime))
+	}
+	if opts.KeepModel != "" {
+		conds = append(conds, "model != ?")
+		args = append(args, opts.KeepModel)
+	}
+	if len(conds) == 0 {
+		return 0, nil
+	}
+
+	result, err := c.db.ExecContext(ctx,
+		"DELETE FROM embeddings WHERE "+strings.Join(conds, " OR "),
+		args...,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("delete embeddings: %w", err)
+	}
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("count removed embeddings: %w", err)
+	}
+
+	if removed > 0 {
+		if _, err := c.db.ExecContext(ctx, "VACUUM"); err != nil {
+			return removed, fmt.Errorf("vacuum: %w", err)
+		}
+	}
+
+	return removed, nil
+}
+
+// addCounter increments the named counter by delta.
+func addCounter(ctx context.Context, tx *sql.Tx, name string, delta int64) error {
+	if delta == 0 {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO counters (name, value) VALUES (?, ?)
+		ON CONFLICT(name) DO UPDATE SET value = value + excluded.value`,
+		name, delta,
+	)
+	if err != nil {
+		return fmt.Errorf("update %s counter: %w", name, err)
+	}
+	return nil
+}
+
+// bytesToFloat32 is the inverse of float32ToBytes.
+func bytesToFloat32(buf []byte) []float32 {
+	floats := make([]float32, len(buf)/4)
+	for i := range floats {
+		floats[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[i*4:]))
+	}
+	return floats
+}
@@ -0,0 +1,155 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jamesainslie/grimoire/internal/store"
+)
+
+func TestEmbeddingCache_LookupStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	cache := newTestCache(t)
+
+	err := cache.Store(ctx, "nomic-embed-text", map[string][]float32{
+		"aaa": {0.1, 0.2, 0.3},
+		"bbb": {0.4, 0.5, 0.6},
+	})
+	if err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+
+	found, err := cache.Lookup(ctx, "nomic-embed-text", []string{"aaa", "ccc"})
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if len(found) != 1 {
+		t.Fatalf("Lookup() = %d entries, want 1", len(found))
+	}
+	if got := found["aaa"]; len(got) != 3 || got[1] != 0.2 {
+		t.Errorf("Lookup()[aaa] = %v, want [0.1 0.2 0.3]", got)
+	}
+
+	// Entries are scoped to their model
+	found, err = cache.Lookup(ctx, "snowflake-arctic-embed:l", []string{"aaa"})
+	if err != nil {
+		t.Fatalf("Lookup(other model) error = %v", err)
+	}
+	if len(found) != 0 {
+		t.Errorf("Lookup(other model) = %d entries, want 0", len(found))
+	}
+
+	stats, err := cache.Stats(ctx)
+	if err != nil {
+		t.Fatalf("Stats() error = %v", err)
+	}
+	if stats.Entries != 2 || stats.Models != 1 {
+		t.Errorf("Stats() = %d entries, %d models; want 2, 1", stats.Entries, stats.Models)
+	}
+	if stats.Hits != 1 || stats.Misses != 2 {
+		t.Errorf("Stats() = %d hits, %d misses; want 1, 2", stats.Hits, stats.Misses)
+	}
+	if stats.Bytes == 0 {
+		t.Error("Stats() Bytes = 0")
+	}
+}
+
+func TestEmbeddingCache_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	cache, err := store.OpenEmbeddingCache(filepath.Join(t.TempDir(), "embeddings.db"))
+	if err != nil {
+		t.Fatalf("OpenEmbeddingCache() error = %v", err)
+	}
+	defer cache.Close()
+
+	// Ingest workers look up and store embeddings at the same time
+	const workers, rounds = 8, 300
+	errs := make(chan error, workers)
+	for w := range workers {
+		go func() {
+			for i := range rounds {
+				hash := fmt.Sprintf("%d-%d", w, i)
+				if _, err := cache.Lookup(ctx, "nomic-embed-text", []string{hash, "shared"}); err != nil {
+					errs <- fmt.Errorf("Lookup() error = %w", err)
+					return
+				}
+				if err := cache.Store(ctx, "nomic-embed-text", map[string][]float32{hash: {1}, "shared": {2}}); err != nil {
+					errs <- fmt.Errorf("Store() error = %w", err)
+					return
+				}
+			}
+			errs <- nil
+		}()
+	}
+	for range workers {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+
+	stats, err := cache.Stats(ctx)
+	if err != nil {
+		t.Fatalf("Stats() error = %v", err)
+	}
+	if lookups := stats.Hits + stats.Misses; lookups != 2*workers*rounds {
+		t.Errorf("Stats() counted %d lookups, want %d", lookups, 2*workers*rounds)
+	}
+}
+
+func TestEmbeddingCache_Prune(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		opts        store.CachePruneOptions
+		wantRemoved int64
+	}{
+		{name: "no conditions", opts: store.CachePruneOptions{}, wantRemoved: 0},
+		{name: "recently used", opts: store.CachePruneOptions{UnusedSince: time.Now().Add(-time.Hour)}, wantRemoved: 0},
+		{name: "unused", opts: store.CachePruneOptions{UnusedSince: time.Now().Add(time.Hour)}, wantRemoved: 3},
+		{name: "other models", opts: store.CachePruneOptions{KeepModel: "nomic-embed-text"}, wantRemoved: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			cache := newTestCache(t)
+			cache.Store(ctx, "nomic-embed-text", map[string][]float32{"aaa": {1}, "bbb": {2}})
+			cache.Store(ctx, "snowflake-arctic-embed:l", map[string][]float32{"aaa": {3}})
+
+			removed, err := cache.Prune(ctx, tt.opts)
+			if err != nil {
+				t.Fatalf("Prune() error = %v", err)
+			}
+			if removed != tt.wantRemoved {
+				t.Errorf("Prune() removed %d, want %d", removed, tt.wantRemoved)
+			}
+
+			stats, _ := cache.Stats(ctx)
+			if stats.Entries != 3-tt.wantRemoved {
+				t.Errorf("Entries = %d, want %d", stats.Entries, 3-tt.wantRemoved)
+			}
+		})
+	}
+}
+
+// newTestCache creates an in-memory embedding cache.
+func newTestCache(t *testing.T) *store.EmbeddingCache {
+	t.Helper()
+
+	cache, err := store.OpenEmbeddingCache(":memory:")
+	if err != nil {
+		t.Fatalf("OpenEmbeddingCache() error = %v", err)
+	}
+	t.Cleanup(func() { cache.Close() })
+	return cache
+}
@@ -0,0 +1,87 @@
+package store
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Citation identifies where a search result came from, so that a reader can
+// check the guidance against the original.
+type Citation struct {
+	Language string // Language name, e.g. "go"
+	Source   string // Source name
+	Tier     int    // Source priority tier; 0 if unset
+	Path     string // Document path within the source
+	Anchor   string // Anchor of the section heading; empty for whole documents
+	Revision string // Commit the source was indexed at; empty if unknown
+	URL      string // Web permalink to the section, or the source URL if none can be built
+}
+
+// String describes where a search result came from: its document path and
+// section anchor, language and source commit, such as
+// "style.md#error-wrapping (go, commit 3f2a9c1d4b5e)".
+func (c Citation) String() string {
+	location := c.Path
+	if c.Anchor != "" {
+		location += "#" + c.Anchor
+	}
+	details := []string{c.Language}
+	if c.Revision != "" {
+		details = append(details, "commit "+c.Revision[:min(len(c.Revision), 12)])
+	}
+	return fmt.Sprintf("%s (%s)", location, strings.Join(details, ", "))
+}
+
+// String describes a source and its tier, such as "uber-guide (tier 2)".
+func (s Source) String() string {
+	if s.Tier == 0 {
+		return s.Name
+	}
+	return fmt.Sprintf("%s (tier %d)", s.Name, s.Tier)
+}
+
+// newCitation returns the citation of a chunk of the document at path in src.
+func newCitation(language string, src *Source, path, anchor string) *Citation {
+	return &Citation{
+		Language: language,
+		Source:   src.Name,
+		Tier:     src.Tier,
+		Path:     path,
+		Anchor:   anchor,
+		Revision: src.Revision,
+		URL:      Permalink(src.URL, src.Revision, path, anchor),
+	}
+}
+
+// blobPaths maps code hosts to the URL path segment that precedes the
+// revision when viewing a file.
+var blobPaths = map[string]string{
+	"github.com":    "blob",
+	"gitlab.com":    "-/blob",
+	"bitbucket.org": "src",
+}
+
+// Permalink returns a web URL for a file in a git repository at a revision,
+// scrolled to anchor if set, such as
+// https://github.com/uber-go/guide/blob/<sha>/style.md#error-wrapping.
+// Without a revision it links to the default branch. For hosts it does not
+// recognise it returns repoURL unchanged.
+func Permalink(repoURL, revision, path, anchor string) string {
+	u, err := url.Parse(strings.TrimSuffix(repoURL, ".git"))
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return repoURL
+	}
+	blob, ok := blobPaths[strings.TrimPrefix(u.Host, "www.")]
+	if !ok || path == "" {
+		return repoURL
+	}
+
+	ref := revision
+	if ref == "" {
+		ref = "HEAD"
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + blob + "/" + ref + "/" + strings.TrimPrefix(path, "/")
+	u.Fragment = anchor
+	return u.String()
+}
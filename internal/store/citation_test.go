@@ -0,0 +1,202 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jamesainslie/grimoire/internal/store"
+)
+
+func TestPermalink(t *testing.T) {
+	t.Parallel()
+
+	const sha = "3f2a9c1d4b5e6f708192a3b4c5d6e7f809102030"
+	tests := []struct {
+		name     string
+		repoURL  string
+		revision string
+		path     string
+		anchor   string
+		want     string
+	}{
+		{
+			name:     "github",
+			repoURL:  "https://github.com/uber-go/guide",
+			revision: sha,
+			path:     "style.md",
+			anchor:   "error-wrapping",
+			want:     "https://github.com/uber-go/guide/blob/" + sha + "/style.md#error-wrapping",
+		},
+		{
+			name:    "github without revision",
+			repoURL: "https://github.com/golang/wiki.git",
+			path:    "CodeReviewComments.md",
+			want:    "https://github.com/golang/wiki/blob/HEAD/CodeReviewComments.md",
+		},
+		{
+			name:     "gitlab nested path",
+			repoURL:  "https://gitlab.com/group/docs/",
+			revision: sha,
+			path:     "guides/errors.md",
+			anchor:   "wrapping",
+			want:     "https://gitlab.com/group/docs/-/blob/" + sha + "/guides/errors.md#wrapping",
+		},
+		{
+			name:     "path needing escapes",
+			repoURL:  "https://github.com/quii/learn-go-with-tests",
+			revision: sha,
+			path:     "docs/Hello World.md",
+			want:     "https://github.com/quii/learn-go-with-tests/blob/" + sha + "/docs/Hello%20World.md",
+		},
+		{
+			name:     "unknown host",
+			repoURL:  "https://go.googlesource.com/go",
+			revision: sha,
+			path:     "doc/go_spec.html",
+			want:     "https://go.googlesource.com/go",
+		},
+		{
+			name:    "ssh url",
+			repoURL: "git@github.com:golang/wiki.git",
+			path:    "Home.md",
+			want:    "git@github.com:golang/wiki.git",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := store.Permalink(tt.repoURL, tt.revision, tt.path, tt.anchor); got != tt.want {
+				t.Errorf("Permalink() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCitation_String(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		citation store.Citation
+		want     string
+	}{
+		{
+			name:     "section at revision",
+			citation: store.Citation{Language: "go", Path: "style.md", Anchor: "error-wrapping", Revision: "3f2a9c1d4b5e6f708192a3b4c5d6e7f809102030"},
+			want:     "style.md#error-wrapping (go, commit 3f2a9c1d4b5e)",
+		},
+		{
+			name:     "whole document",
+			citation: store.Citation{Language: "go", Path: "CodeReviewComments.md"},
+			want:     "CodeReviewComments.md (go)",
+		},
+		{
+			name:     "short revision",
+			citation: store.Citation{Language: "python", Path: "pep-0008.txt", Revision: "abc123"},
+			want:     "pep-0008.txt (python, commit abc123)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.citation.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSource_String(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		source store.Source
+		want   string
+	}{
+		{name: "tiered", source: store.Source{Name: "uber-guide", Tier: 2}, want: "uber-guide (tier 2)"},
+		{name: "untiered", source: store.Source{Name: "uber-guide"}, want: "uber-guide"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.source.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStore_SearchResultCitation(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	lang, _ := s.CreateLanguage(ctx, "go", "Go")
+	src, _ := s.CreateSource(ctx, lang.ID, "uber-style-guide", "git", "https://github.com/uber-go/guide")
+	if err := s.SetSourceTier(ctx, src.ID, 2); err != nil {
+		t.Fatalf("SetSourceTier() error = %v", err)
+	}
+	const sha = "3f2a9c1d4b5e6f708192a3b4c5d6e7f809102030"
+	if err := s.SetSourceRevision(ctx, src.ID, sha); err != nil {
+		t.Fatalf("SetSourceRevision() error = %v", err)
+	}
+	if err := s.SetSourceRevision(ctx, 9999, sha); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("SetSourceRevision(missing) error = %v, want ErrNotFound", err)
+	}
+
+	embedding := make([]float32, 1024)
+	embedding[0] = 1
+	_, err := s.ReplaceDocument(ctx, &store.Document{SourceID: src.ID, Path: "style.md", Title: "Uber Go Style Guide"}, []store.NewChunk{{
+		Level:       "section",
+		Title:       "Error Wrapping",
+		Content:     strings.Repeat("Add context to errors with fmt.Errorf and the %w verb so callers can unwrap them. ", 2),
+		Breadcrumbs: []string{"Uber Go Style Guide", "Errors", "Error Wrapping"},
+		Anchor:      "error-wrapping",
+		Embedding:   embedding,
+	}})
+	if err != nil {
+		t.Fatalf("ReplaceDocument() error = %v", err)
+	}
+
+	results, err := s.SearchChunksHybrid(ctx, embedding, "wrapping errors", store.SearchOptions{}, 5)
+	if err != nil {
+		t.Fatalf("SearchChunksHybrid() error = %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("SearchChunksHybrid() = %d results, want 1", len(results))
+	}
+
+	want := store.Citation{
+		Language: "go",
+		Source:   "uber-style-guide",
+		Tier:     2,
+		Path:     "style.md",
+		Anchor:   "error-wrapping",
+		Revision: sha,
+		URL:      "https://github.com/uber-go/guide/blob/" + sha + "/style.md#error-wrapping",
+	}
+	if got := results[0].Citation; got == nil || *got != want {
+		t.Errorf("Citation = %+v, want %+v", got, want)
+	}
+	if results[0].Chunk.Anchor != "error-wrapping" {
+		t.Errorf("Chunk.Anchor = %q, want error-wrapping", results[0].Chunk.Anchor)
+	}
+
+	got, err := s.GetSource(ctx, lang.ID, "uber-style-guide")
+	if err != nil {
+		t.Fatalf("GetSource() error = %v", err)
+	}
+	if got.Revision != sha {
+		t.Errorf("GetSource().Revision = %q, want %q", got.Revision, sha)
+	}
+}
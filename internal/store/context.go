@@ -0,0 +1,317 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ChunkContext is a chunk together with the parts of its document an agent
+// needs to read it in context.
+type ChunkContext struct {
+	Chunk       *Chunk
+	Document    *Document
+	Parent      *Chunk   // Parent chunk, normally the document summary; nil if none
+	Before      []*Chunk // Preceding neighbours, in document order
+	After       []*Chunk // Following neighbours, in document order
+	Breadcrumbs []string // Headings from the document title down to the chunk's section
+}
+
+// GetChunkContext returns a chunk with its parent and up to window
+// neighbours on each side. Neighbours are the chunks next to it in its
+// document at the same level under the same parent; paragraphs only
+// neighbour paragraphs of the same section.
+func (s *Store) GetChunkContext(ctx context.Context, chunkID int64, window int) (*ChunkContext, error) {
+	var docID int64
+	err := s.db.QueryRowContext(ctx, "SELECT document_id FROM chunks WHERE id = ?", chunkID).Scan(&docID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("chunk %d: %w", chunkID, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query chunk: %w", err)
+	}
+
+	doc, err := s.documentByID(ctx, docID)
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := s.documentChunks(ctx, docID)
+	if err != nil {
+		return nil, err
+	}
+
+	siblings, i := neighbours(chunks, chunkID)
+	if i < 0 {
+		return nil, fmt.Errorf("chunk %d: %w", chunkID, ErrNotFound)
+	}
+	window = max(window, 0)
+	chunk := siblings[i]
+	return &ChunkContext{
+		Chunk:       chunk,
+		Document:    doc,
+		Parent:      parentOf(chunks, chunk),
+		Before:      siblings[max(i-window, 0):i],
+		After:       siblings[i+1 : min(i+1+window, len(siblings))],
+		Breadcrumbs: breadcrumbs(doc, chunk),
+	}, nil
+}
+
+// Passage is a run of neighbouring chunks covering one or more search hits.
+type Passage struct {
+	Chunks      []*Chunk // In document order
+	Hits        []int64  // IDs of the chunks that were search results, best first
+	Document    *Document
+	Source      *Source
+	Citation    *Citation // Citation of the best hit
+	Parent      *Chunk    // Parent of the chunks, normally the document summary; nil if none
+	Breadcrumbs []string
+	Distance    float64 // Distance of the best hit
+	Truncated   bool    // Content was cut short to fit a token budget
+}
+
+// Content returns the text of the passage's chunks, separated by blank lines.
+func (p *Passage) Content() string {
+	parts := make([]string, len(p.Chunks))
+	for i, c := range p.Chunks {
+		parts[i] = c.Content
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// ExpandResults widens each search result with up to window neighbours on
+// each side, as GetChunkContext, and merges results whose passages overlap
+// or touch into one contiguous passage. Passages are ordered by their best
+// hit, so ranked results keep their order.
+func (s *Store) ExpandResults(ctx context.Context, results []*SearchResult, window int) ([]*Passage, error) {
+	window = max(window, 0)
+
+	type loaded struct {
+		doc    *Document
+		chunks []*Chunk
+	}
+	documents := make(map[int64]*loaded)
+
+	// hit is a search result widened to siblings[lo:hi+1]
+	type hit struct {
+		rank     int
+		result   *SearchResult
+		doc      *loaded
+		siblings []*Chunk
+		index    int
+		lo, hi   int
+	}
+	// Hits are grouped by run, keyed by its first chunk, in order of the
+	// run's best hit
+	runs := make(map[*Chunk][]*hit)
+	var firsts []*Chunk
+
+	for rank, r := range results {
+		d, ok := documents[r.Chunk.DocumentID]
+		if !ok {
+			doc, err := s.documentByID(ctx, r.Chunk.DocumentID)
+			if err != nil {
+				return nil, err
+			}
+			chunks, err := s.documentChunks(ctx, r.Chunk.DocumentID)
+			if err != nil {
+				return nil, err
+			}
+			d = &loaded{doc: doc, chunks: chunks}
+			documents[r.Chunk.DocumentID] = d
+		}
+
+		siblings, i := neighbours(d.chunks, r.Chunk.ID)
+		if i < 0 {
+			// Replaced since the search ran; keep the hit on its own
+			siblings, i = []*Chunk{r.Chunk}, 0
+		}
+		h := &hit{
+			rank:     rank,
+			result:   r,
+			doc:      d,
+			siblings: siblings,
+			index:    i,
+			lo:       max(i-window, 0),
+			hi:       min(i+window, len(siblings)-1),
+		}
+		if _, ok := runs[siblings[0]]; !ok {
+			firsts = append(firsts, siblings[0])
+		}
+		runs[siblings[0]] = append(runs[siblings[0]], h)
+	}
+
+	// span is a passage under construction: siblings[lo:hi+1], covering
+	// hits, best first
+	type span struct {
+		hits   []*hit
+		lo, hi int
+	}
+	var spans []*span
+	for _, first := range firsts {
+		// Coalescing in document order merges every overlapping or touching
+		// pair, whatever order the hits were ranked in
+		run := runs[first]
+		slices.SortStableFunc(run, func(a, b *hit) int { return cmp.Compare(a.lo, b.lo) })
+		var current *span
+		for _, h := range run {
+			if current != nil && h.lo <= current.hi+1 {
+				current.hi = max(current.hi, h.hi)
+				current.hits = append(current.hits, h)
+				continue
+			}
+			current = &span{hits: []*hit{h}, lo: h.lo, hi: h.hi}
+			spans = append(spans, current)
+		}
+	}
+
+	byRank := func(a, b *hit) int { return cmp.Compare(a.rank, b.rank) }
+	for _, sp := range spans {
+		slices.SortFunc(sp.hits, byRank)
+	}
+	slices.SortFunc(spans, func(a, b *span) int { return byRank(a.hits[0], b.hits[0]) })
+
+	passages := make([]*Passage, len(spans))
+	for i, sp := range spans {
+		best := sp.hits[0]
+		chunk := best.siblings[best.index]
+		passage := &Passage{
+			Chunks:      best.siblings[sp.lo : sp.hi+1],
+			Document:    best.doc.doc,
+			Source:      best.result.Source,
+			Citation:    best.result.Citation,
+			Parent:      parentOf(best.doc.chunks, chunk),
+			Breadcrumbs: breadcrumbs(best.doc.doc, chunk),
+			Distance:    best.result.Distance,
+		}
+		for _, h := range sp.hits {
+			passage.Hits = append(passage.Hits, h.result.Chunk.ID)
+		}
+		passages[i] = passage
+	}
+	return passages, nil
+}
+
+// documentByID returns a document by its ID.
+func (s *Store) documentByID(ctx context.Context, id int64) (*Document, error) {
+	var doc Document
+	var contentHash sql.NullString
+	var fetchedAt sql.NullTime
+	err := s.db.QueryRowContext(ctx,
+		"SELECT id, source_id, path, COALESCE(title, ''), content_hash, fetched_at FROM documents WHERE id = ?",
+		id,
+	).Scan(&doc.ID, &doc.SourceID, &doc.Path, &doc.Title, &contentHash, &fetchedAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("document %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query document: %w", err)
+	}
+
+	doc.ContentHash = contentHash.String
+	doc.FetchedAt = fetchedAt.Time
+	return &doc, nil
+}
+
+// documentChunks returns the chunks of a document in document order, which
+// is the order they were inserted in.
+func (s *Store) documentChunks(ctx context.Context, documentID int64) ([]*Chunk, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+chunkColumns+`
+		FROM chunks c
+		WHERE c.document_id = ?
+		ORDER BY c.id
+	`, documentID)
+	if err != nil {
+		return nil, fmt.Errorf("query document chunks: %w", err)
+	}
+	defer rows.Close()
+
+	var chunks []*Chunk
+	for rows.Next() {
+		chunk, err := scanChunk(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan chunk: %w", err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate document chunks: %w", err)
+	}
+	return chunks, nil
+}
+
+// neighbours returns the run of chunks in document order that chunkID
+// belongs to, and its index in the run, or -1 if chunks does not contain it.
+// A run is a stretch of consecutive chunks sharing a parent and level;
+// paragraphs, which are the pieces of one split section, also share the
+// section. Any other chunk in between ends the run, so a section never
+// neighbours one beyond a section split into paragraphs.
+func neighbours(chunks []*Chunk, chunkID int64) ([]*Chunk, int) {
+	index := slices.IndexFunc(chunks, func(c *Chunk) bool { return c.ID == chunkID })
+	if index < 0 {
+		return nil, -1
+	}
+
+	chunk := chunks[index]
+	inRun := func(c *Chunk) bool {
+		if c.Level != chunk.Level || !sameParent(c, chunk) {
+			return false
+		}
+		return c.Level != "paragraph" || (c.Title == chunk.Title && sameBreadcrumbs(c, chunk))
+	}
+	lo, hi := index, index+1
+	for lo > 0 && inRun(chunks[lo-1]) {
+		lo--
+	}
+	for hi < len(chunks) && inRun(chunks[hi]) {
+		hi++
+	}
+	return chunks[lo:hi], index - lo
+}
+
+// sameParent reports whether a and b have the same parent chunk.
+func sameParent(a, b *Chunk) bool {
+	if a.ParentChunkID == nil || b.ParentChunkID == nil {
+		return a.ParentChunkID == b.ParentChunkID
+	}
+	return *a.ParentChunkID == *b.ParentChunkID
+}
+
+// sameBreadcrumbs reports whether a and b have the same breadcrumbs.
+func sameBreadcrumbs(a, b *Chunk) bool {
+	return strings.Join(a.Breadcrumbs, "\n") == strings.Join(b.Breadcrumbs, "\n")
+}
+
+// parentOf returns the parent of chunk among chunks, or nil.
+func parentOf(chunks []*Chunk, chunk *Chunk) *Chunk {
+	if chunk.ParentChunkID == nil {
+		return nil
+	}
+	for _, c := range chunks {
+		if c.ID == *chunk.ParentChunkID {
+			return c
+		}
+	}
+	return nil
+}
+
+// breadcrumbs returns the headings leading to chunk. Chunks indexed before
+// breadcrumbs were recorded get the document title and their own title.
+func breadcrumbs(doc *Document, chunk *Chunk) []string {
+	if len(chunk.Breadcrumbs) > 0 {
+		return chunk.Breadcrumbs
+	}
+	var crumbs []string
+	if doc.Title != "" {
+		crumbs = append(crumbs, doc.Title)
+	}
+	if chunk.Title != "" && chunk.Title != doc.Title {
+		crumbs = append(crumbs, chunk.Title)
+	}
+	return crumbs
+}
@@ -0,0 +1,254 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jamesainslie/grimoire/internal/store"
+)
+
+func TestStore_GetChunkContext(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s, ids := newContextStore(t)
+
+	tests := []struct {
+		name            string
+		chunk           string
+		window          int
+		wantBefore      []string
+		wantAfter       []string
+		wantBreadcrumbs []string
+	}{
+		{
+			name:            "paragraph",
+			chunk:           "bravo",
+			window:          1,
+			wantBefore:      []string{"alpha"},
+			wantAfter:       []string{"charlie"},
+			wantBreadcrumbs: []string{"Errors", "Wrapping"},
+		},
+		{
+			name:            "wide window",
+			chunk:           "bravo",
+			window:          5,
+			wantBefore:      []string{"alpha"},
+			wantAfter:       []string{"charlie", "delta"},
+			wantBreadcrumbs: []string{"Errors", "Wrapping"},
+		},
+		{
+			name:            "no window",
+			chunk:           "delta",
+			wantBreadcrumbs: []string{"Errors", "Wrapping"},
+		},
+		{
+			name:            "section",
+			chunk:           "sentinel",
+			window:          1,
+			wantAfter:       []string{"panics"},
+			wantBreadcrumbs: []string{"Errors", "Sentinels"},
+		},
+		{
+			name:            "section before split section",
+			chunk:           "one",
+			window:          1,
+			wantBreadcrumbs: []string{"Split", "One"},
+		},
+		{
+			name:            "section after split section",
+			chunk:           "three",
+			window:          1,
+			wantBreadcrumbs: []string{"Split", "Three"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cc, err := s.GetChunkContext(ctx, ids[tt.chunk], tt.window)
+			if err != nil {
+				t.Fatalf("GetChunkContext() error = %v", err)
+			}
+			if cc.Chunk.ID != ids[tt.chunk] {
+				t.Errorf("Chunk.ID = %d, want %d", cc.Chunk.ID, ids[tt.chunk])
+			}
+			if want := tt.wantBreadcrumbs[0]; cc.Document.Title != want {
+				t.Errorf("Document.Title = %q, want %q", cc.Document.Title, want)
+			}
+			if cc.Parent == nil || cc.Parent.Level != "summary" {
+				t.Errorf("Parent = %+v, want the summary chunk", cc.Parent)
+			}
+			if got := markers(ids, cc.Before); !slices.Equal(got, tt.wantBefore) {
+				t.Errorf("Before = %v, want %v", got, tt.wantBefore)
+			}
+			if got := markers(ids, cc.After); !slices.Equal(got, tt.wantAfter) {
+				t.Errorf("After = %v, want %v", got, tt.wantAfter)
+			}
+			if !slices.Equal(cc.Breadcrumbs, tt.wantBreadcrumbs) {
+				t.Errorf("Breadcrumbs = %v, want %v", cc.Breadcrumbs, tt.wantBreadcrumbs)
+			}
+		})
+	}
+
+	_, err := s.GetChunkContext(ctx, 9999, 1)
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetChunkContext(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStore_ExpandResults(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s, ids := newContextStore(t)
+
+	tests := []struct {
+		name   string
+		hits   []string
+		window int
+		want   [][]string // Chunks of each passage
+	}{
+		{name: "apart", hits: []string{"alpha", "charlie"}, want: [][]string{{"alpha"}, {"charlie"}}},
+		{name: "adjacent", hits: []string{"charlie", "bravo"}, want: [][]string{{"bravo", "charlie"}}},
+		{name: "overlapping windows", hits: []string{"alpha", "charlie"}, window: 1, want: [][]string{{"alpha", "bravo", "charlie", "delta"}}},
+		{name: "other section", hits: []string{"delta", "sentinel"}, window: 1, want: [][]string{{"charlie", "delta"}, {"sentinel", "panics"}}},
+		{name: "bridging hit", hits: []string{"alpha", "charlie", "bravo"}, want: [][]string{{"alpha", "bravo", "charlie"}}},
+		{name: "bridging window", hits: []string{"alpha", "delta", "bravo"}, window: 1, want: [][]string{{"alpha", "bravo", "charlie", "delta"}}},
+		{name: "ranked apart", hits: []string{"delta", "alpha"}, want: [][]string{{"delta"}, {"alpha"}}},
+		{name: "split section between", hits: []string{"one", "three"}, window: 1, want: [][]string{{"one"}, {"three"}}},
+		{name: "split section and its neighbour", hits: []string{"one", "echo"}, window: 1, want: [][]string{{"one"}, {"echo", "foxtrot"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := make([]*store.SearchResult, len(tt.hits))
+			for i, hit := range tt.hits {
+				cc, err := s.GetChunkContext(ctx, ids[hit], 0)
+				if err != nil {
+					t.Fatalf("GetChunkContext(%s) error = %v", hit, err)
+				}
+				results[i] = &store.SearchResult{
+					Chunk:    cc.Chunk,
+					Source:   &store.Source{Name: "go-wiki"},
+					Distance: float64(i) / 10,
+				}
+			}
+
+			passages, err := s.ExpandResults(ctx, results, tt.window)
+			if err != nil {
+				t.Fatalf("ExpandResults() error = %v", err)
+			}
+			if len(passages) != len(tt.want) {
+				t.Fatalf("ExpandResults() = %d passages, want %d", len(passages), len(tt.want))
+			}
+			for i, p := range passages {
+				if got := markers(ids, p.Chunks); !slices.Equal(got, tt.want[i]) {
+					t.Errorf("passage %d chunks = %v, want %v", i, got, tt.want[i])
+				}
+				if p.Source == nil || p.Source.Name != "go-wiki" {
+					t.Errorf("passage %d source = %v, want go-wiki", i, p.Source)
+				}
+			}
+			if passages[0].Distance != 0 || passages[0].Hits[0] != ids[tt.hits[0]] {
+				t.Errorf("first passage = hit %d at %v, want the best hit first", passages[0].Hits[0], passages[0].Distance)
+			}
+			if !strings.Contains(passages[0].Content(), tt.want[0][0]) {
+				t.Errorf("Content() = %q, want it to contain %q", passages[0].Content(), tt.want[0][0])
+			}
+		})
+	}
+}
+
+// newContextStore returns a store holding two documents shaped like the
+// chunker's output. errors.md has a summary, a section split into four
+// paragraphs, and two whole sections; split.md has a summary and a section
+// split into four paragraphs between two whole sections. ids maps each
+// chunk's marker word to its ID, and "document" and "split" to the IDs of
+// the documents.
+func newContextStore(t *testing.T) (*store.Store, map[string]int64) {
+	t.Helper()
+
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	lang, _ := s.CreateLanguage(ctx, "go", "Go")
+	src, _ := s.CreateSource(ctx, lang.ID, "go-wiki", "git", "https://github.com/golang/wiki")
+
+	summary := 0
+	chunk := func(docTitle, level, title, marker string) store.NewChunk {
+		c := store.NewChunk{
+			Level:       level,
+			Title:       title,
+			Content:     marker + " " + strings.Repeat("Errors are values in Go and deserve the same care as any other value. ", 2),
+			Breadcrumbs: []string{docTitle},
+		}
+		if level != "summary" {
+			c.ParentIndex = &summary
+			c.Breadcrumbs = append(c.Breadcrumbs, title)
+		}
+		return c
+	}
+	documents := []struct {
+		key, path, title string
+		chunks           [][3]string // Level, title and marker of each chunk
+	}{
+		{key: "document", path: "errors.md", title: "Errors", chunks: [][3]string{
+			{"summary", "Errors", "overview"},
+			{"paragraph", "Wrapping", "alpha"},
+			{"paragraph", "Wrapping", "bravo"},
+			{"paragraph", "Wrapping", "charlie"},
+			{"paragraph", "Wrapping", "delta"},
+			{"section", "Sentinels", "sentinel"},
+			{"section", "Panics", "panics"},
+		}},
+		{key: "split", path: "split.md", title: "Split", chunks: [][3]string{
+			{"summary", "Split", "outline"},
+			{"section", "One", "one"},
+			{"paragraph", "Two", "echo"},
+			{"paragraph", "Two", "foxtrot"},
+			{"paragraph", "Two", "golf"},
+			{"paragraph", "Two", "hotel"},
+			{"section", "Three", "three"},
+		}},
+	}
+
+	ids := make(map[string]int64)
+	for _, d := range documents {
+		chunks := make([]store.NewChunk, len(d.chunks))
+		for i, c := range d.chunks {
+			chunks[i] = chunk(d.title, c[0], c[1], c[2])
+		}
+		doc, err := s.ReplaceDocument(ctx, &store.Document{SourceID: src.ID, Path: d.path, Title: d.title}, chunks)
+		if err != nil {
+			t.Fatalf("ReplaceDocument(%s) error = %v", d.path, err)
+		}
+		ids[d.key] = doc.ID
+
+		for _, c := range d.chunks {
+			marker := c[2]
+			found, err := s.SearchChunksFTS(ctx, marker, store.SearchOptions{}, 1)
+			if err != nil || len(found) != 1 {
+				t.Fatalf("SearchChunksFTS(%q) = %d chunks, %v; want 1", marker, len(found), err)
+			}
+			ids[marker] = found[0].ID
+		}
+	}
+	return s, ids
+}
+
+// markers returns the marker word of each chunk, as assigned by
+// newContextStore.
+func markers(ids map[string]int64, chunks []*store.Chunk) []string {
+	var words []string
+	for _, c := range chunks {
+		for word, id := range ids {
+			if id == c.ID && word != "document" && word != "split" {
+				words = append(words, word)
+			}
+		}
+	}
+	return words
+}
@@ -0,0 +1,187 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// DeleteDocument deletes a document together with its chunks, FTS entries
+// and embeddings.
+func (s *Store) DeleteDocument(ctx context.Context, documentID int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := deleteDocument(ctx, tx, documentID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteSource deletes a source and all of its documents, chunks, FTS entries
+// and embeddings in a single transaction.
+func (s *Store) DeleteSource(ctx context.Context, sourceID int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := deleteSource(ctx, tx, sourceID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteLanguage deletes a language and every source, document, chunk, FTS
+// entry and embedding that belongs to it in a single transaction.
+func (s *Store) DeleteLanguage(ctx context.Context, languageID int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM sources WHERE language_id = ?", languageID)
+	if err != nil {
+		return fmt.Errorf("query sources: %w", err)
+	}
+	var sourceIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan source: %w", err)
+		}
+		sourceIDs = append(sourceIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate sources: %w", err)
+	}
+
+	for _, id := range sourceIDs {
+		if err := deleteSource(ctx, tx, id); err != nil {
+			return err
+		}
+	}
+
+	if err := deleteRow(ctx, tx, "languages", languageID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// PruneDocuments deletes every document of a source whose path is not in keep,
+// together with its chunks, FTS entries and embeddings. It returns the paths
+// of the deleted documents.
+func (s *Store) PruneDocuments(ctx context.Context, sourceID int64, keep []string) ([]string, error) {
+	keepSet := make(map[string]bool, len(keep))
+	for _, path := range keep {
+		keepSet[path] = true
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT id, path FROM documents WHERE source_id = ?", sourceID)
+	if err != nil {
+		return nil, fmt.Errorf("query documents: %w", err)
+	}
+
+	var staleIDs []int64
+	var stalePaths []string
+	for rows.Next() {
+		var id int64
+		var path string
+		if err := rows.Scan(&id, &path); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan document: %w", err)
+		}
+		if !keepSet[path] {
+			staleIDs = append(staleIDs, id)
+			stalePaths = append(stalePaths, path)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate documents: %w", err)
+	}
+
+	for _, id := range staleIDs {
+		if err := deleteDocument(ctx, tx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+
+	return stalePaths, nil
+}
+
+// deleteDocument removes a document and everything derived from it.
+func deleteDocument(ctx context.Context, tx *sql.Tx, documentID int64) error {
+	if err := deleteDocumentChunks(ctx, tx, documentID); err != nil {
+		return err
+	}
+
+	return deleteRow(ctx, tx, "documents", documentID)
+}
+
+// deleteDocumentChunks removes a document's chunks. FTS entries and embeddings
+// are removed by the chunks_ad and chunks_vec_ad triggers.
+func deleteDocumentChunks(ctx context.Context, tx *sql.Tx, documentID int64) error {
+	_, err := tx.ExecContext(ctx, "DELETE FROM chunks WHERE document_id = ?", documentID)
+	if err != nil {
+		return fmt.Errorf("delete chunks: %w", err)
+	}
+	return nil
+}
+
+// deleteSource removes a source and everything derived from it.
+func deleteSource(ctx context.Context, tx *sql.Tx, sourceID int64) error {
+	_, err := tx.ExecContext(ctx,
+		"DELETE FROM chunks WHERE document_id IN (SELECT id FROM documents WHERE source_id = ?)",
+		sourceID,
+	)
+	if err != nil {
+		return fmt.Errorf("delete chunks: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM documents WHERE source_id = ?", sourceID)
+	if err != nil {
+		return fmt.Errorf("delete documents: %w", err)
+	}
+
+	return deleteRow(ctx, tx, "sources", sourceID)
+}
+
+// deleteRow deletes a single row by ID and returns ErrNotFound if it did not exist.
+// table must be a trusted constant.
+func deleteRow(ctx context.Context, tx *sql.Tx, table string, id int64) error {
+	result, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("delete from %s: %w", table, err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s %d: %w", table, id, ErrNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jamesainslie/grimoire/internal/store"
+)
+
+func TestStore_DeleteDocument(t *testing.T) {
+	t.Parallel()
+
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	lang, _ := s.CreateLanguage(ctx, "go", "Go")
+	src, _ := s.CreateSource(ctx, lang.ID, "uber-guide", "git", "https://github.com/uber-go/guide")
+	doc := seedDocument(t, s, src.ID, "style.md")
+	seedDocument(t, s, src.ID, "other.md")
+
+	if err := s.DeleteDocument(ctx, doc.ID); err != nil {
+		t.Fatalf("DeleteDocument() error = %v", err)
+	}
+
+	assertCounts(t, s, 1, 1, 1)
+	if _, err := s.GetDocumentByPath(ctx, src.ID, "style.md"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetDocumentByPath() error = %v, want ErrNotFound", err)
+	}
+
+	if err := s.DeleteDocument(ctx, doc.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("DeleteDocument(deleted) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStore_DeleteSource(t *testing.T) {
+	t.Parallel()
+
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	lang, _ := s.CreateLanguage(ctx, "go", "Go")
+	guide, _ := s.CreateSource(ctx, lang.ID, "uber-guide", "git", "https://github.com/uber-go/guide")
+	wiki, _ := s.CreateSource(ctx, lang.ID, "go-wiki", "git", "https://github.com/golang/wiki")
+	seedDocument(t, s, guide.ID, "style.md")
+	seedDocument(t, s, guide.ID, "README.md")
+	seedDocument(t, s, wiki.ID, "CodeReviewComments.md")
+
+	if err := s.DeleteSource(ctx, guide.ID); err != nil {
+		t.Fatalf("DeleteSource() error = %v", err)
+	}
+
+	assertCounts(t, s, 1, 1, 1)
+	if _, err := s.GetSource(ctx, lang.ID, "uber-guide"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetSource() error = %v, want ErrNotFound", err)
+	}
+
+	results, err := s.SearchChunksFTS(ctx, "goroutines", store.SearchOptions{}, 10)
+	if err != nil {
+		t.Fatalf("SearchChunksFTS() error = %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("SearchChunksFTS() = %d results, want 1", len(results))
+	}
+}
+
+func TestStore_DeleteLanguage(t *testing.T) {
+	t.Parallel()
+
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	goLang, _ := s.CreateLanguage(ctx, "go", "Go")
+	rustLang, _ := s.CreateLanguage(ctx, "rust", "Rust")
+	guide, _ := s.CreateSource(ctx, goLang.ID, "uber-guide", "git", "https://github.com/uber-go/guide")
+	wiki, _ := s.CreateSource(ctx, goLang.ID, "go-wiki", "git", "https://github.com/golang/wiki")
+	book, _ := s.CreateSource(ctx, rustLang.ID, "rust-book", "git", "https://github.com/rust-lang/book")
+	seedDocument(t, s, guide.ID, "style.md")
+	seedDocument(t, s, wiki.ID, "CodeReviewComments.md")
+	seedDocument(t, s, book.ID, "ch16.md")
+
+	if err := s.DeleteLanguage(ctx, goLang.ID); err != nil {
+		t.Fatalf("DeleteLanguage() error = %v", err)
+	}
+
+	assertCounts(t, s, 1, 1, 1)
+	stats, _ := s.GetStats(ctx)
+	if stats.Languages != 1 || stats.Sources != 1 {
+		t.Errorf("stats = %+v, want 1 language and 1 source", stats)
+	}
+	if _, err := s.GetLanguage(ctx, "go"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetLanguage() error = %v, want ErrNotFound", err)
+	}
+
+	if err := s.DeleteLanguage(ctx, goLang.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("DeleteLanguage(deleted) error = %v, want ErrNotFound", err)
+	}
+}
+
+// seedDocument stores a document with a single embedded chunk.
+func seedDocument(t *testing.T, s *store.Store, sourceID int64, path string) *store.Document {
+	t.Helper()
+
+	embedding := make([]float32, 1024)
+	embedding[0] = 1.0
+
+	doc, err := s.ReplaceDocument(context.Background(), &store.Document{SourceID: sourceID, Path: path, Title: path}, []store.NewChunk{
+		{Level: "section", Title: "Goroutines", Content: strings.Repeat("Do not fire-and-forget goroutines. ", 5), Embedding: embedding},
+	})
+	if err != nil {
+		t.Fatalf("ReplaceDocument(%s) error = %v", path, err)
+	}
+	return doc
+}
+
+// assertCounts checks the number of documents, chunks and embeddings in the store.
+func assertCounts(t *testing.T, s *store.Store, documents, chunks, embeddings int64) {
+	t.Helper()
+
+	stats, err := s.GetStats(context.Background())
+	if err != nil {
+		t.Fatalf("GetStats() error = %v", err)
+	}
+	if stats.Documents != documents || stats.Chunks != chunks || stats.Embeddings != embeddings {
+		t.Errorf("stats = %d documents, %d chunks, %d embeddings; want %d, %d, %d",
+			stats.Documents, stats.Chunks, stats.Embeddings, documents, chunks, embeddings)
+	}
+}
@@ -0,0 +1,114 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// diversifies reports whether results are re-ranked rather than taken in
+// order of relevance.
+func (o SearchOptions) diversifies() bool {
+	return o.Diversity > 0 || o.MaxPerDocument > 0
+}
+
+// diversify returns up to limit of results, which are ordered by relevance;
+// relevance[i] is the relevance of results[i], higher being better.
+//
+// With opts.Diversity set, results are picked by Maximal Marginal Relevance:
+// each pick maximizes (1-d)*relevance - d*similarity, where similarity is the
+// largest cosine similarity between the candidate's stored vector and those
+// already picked. Near-duplicates of a chosen chunk therefore fall behind
+// less relevant chunks that add something new. With opts.MaxPerDocument set,
+// candidates from a document that already has that many results are skipped.
+// Distances are left as they were, so they need not increase down the list.
+func (s *Store) diversify(ctx context.Context, results []*SearchResult, relevance []float64, opts SearchOptions, limit int) ([]*SearchResult, error) {
+	if !opts.diversifies() {
+		return results[:min(len(results), limit)], nil
+	}
+
+	var vectors map[int64][]float32
+	if opts.Diversity > 0 {
+		var err error
+		if vectors, err = s.chunkVectors(ctx, results); err != nil {
+			return nil, err
+		}
+	}
+
+	lambda := 1 - opts.Diversity
+	perDocument := make(map[int64]int)
+	remaining := append([]*SearchResult(nil), results...)
+	scores := append([]float64(nil), relevance...)
+	// closest[i] is the largest similarity of remaining[i] to a picked result
+	closest := make([]float64, len(remaining))
+
+	var picked []*SearchResult
+	for len(picked) < limit && len(remaining) > 0 {
+		best := -1
+		bestScore := math.Inf(-1)
+		for i, r := range remaining {
+			if opts.MaxPerDocument > 0 && perDocument[r.Chunk.DocumentID] >= opts.MaxPerDocument {
+				continue
+			}
+			score := lambda*scores[i] - opts.Diversity*closest[i]
+			if score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		if best < 0 {
+			break // Every remaining candidate is from a capped document
+		}
+
+		chosen := remaining[best]
+		picked = append(picked, chosen)
+		perDocument[chosen.Chunk.DocumentID]++
+		remaining = append(remaining[:best], remaining[best+1:]...)
+		scores = append(scores[:best], scores[best+1:]...)
+		closest = append(closest[:best], closest[best+1:]...)
+
+		if v := vectors[chosen.Chunk.ID]; v != nil {
+			for i, r := range remaining {
+				closest[i] = max(closest[i], cosineSimilarity(v, vectors[r.Chunk.ID]))
+			}
+		}
+	}
+	return picked, nil
+}
+
+// chunkVectors returns the stored vectors of the results' chunks. Chunks
+// without a vector are omitted.
+func (s *Store) chunkVectors(ctx context.Context, results []*SearchResult) (map[int64][]float32, error) {
+	vectors := make(map[int64][]float32, len(results))
+	for _, r := range results {
+		var buf []byte
+		err := s.db.QueryRowContext(ctx, "SELECT embedding FROM chunks_vec WHERE chunk_id = ?", r.Chunk.ID).Scan(&buf)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			return nil, fmt.Errorf("get vector for chunk %d: %w", r.Chunk.ID, err)
+		}
+		vectors[r.Chunk.ID] = bytesToFloat32(buf)
+	}
+	return vectors, nil
+}
+
+// cosineSimilarity returns the cosine similarity of a and b, or 0 if either
+// is missing or zero.
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
@@ -0,0 +1,102 @@
+package store_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jamesainslie/grimoire/internal/store"
+)
+
+func TestStore_Search_Diversity(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	lang, _ := s.CreateLanguage(ctx, "go", "Go")
+	src, _ := s.CreateSource(ctx, lang.ID, "learn-go-with-tests", "git", "https://github.com/quii/learn-go-with-tests")
+
+	vector := func(x, y float32) []float32 {
+		v := make([]float32, 1024)
+		v[0], v[1] = x, y
+		return v
+	}
+	chunk := func(title string, embedding []float32) store.NewChunk {
+		return store.NewChunk{
+			Level:     "paragraph",
+			Title:     title,
+			Content:   strings.Repeat("Errors are values: check each error, wrap it with context and return it to the caller. ", 2) + title,
+			Embedding: embedding,
+		}
+	}
+
+	// Two near-identical paragraphs from one chapter, and a less relevant
+	// but different one from another. The latter's vector is long, so it is
+	// far from the query by distance although close by angle.
+	chapter, err := s.ReplaceDocument(ctx, &store.Document{SourceID: src.ID, Path: "errors.md"}, []store.NewChunk{
+		chunk("Errors one", vector(1, 0)),
+		chunk("Errors two", vector(0.99, 0.14)),
+	})
+	if err != nil {
+		t.Fatalf("ReplaceDocument() error = %v", err)
+	}
+	other, err := s.ReplaceDocument(ctx, &store.Document{SourceID: src.ID, Path: "wrapping.md"}, []store.NewChunk{
+		chunk("Wrapping", vector(7, 7)),
+	})
+	if err != nil {
+		t.Fatalf("ReplaceDocument() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		opts    store.SearchOptions
+		want    []int64 // Document of each result
+		wantErr bool
+	}{
+		{name: "relevance", want: []int64{chapter.ID, chapter.ID}},
+		{name: "diversity", opts: store.SearchOptions{Diversity: 0.7}, want: []int64{chapter.ID, other.ID}},
+		{name: "per document", opts: store.SearchOptions{MaxPerDocument: 1}, want: []int64{chapter.ID, other.ID}},
+		{name: "diversity too high", opts: store.SearchOptions{Diversity: 1.5}, wantErr: true},
+		{name: "negative cap", opts: store.SearchOptions{MaxPerDocument: -1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			searches := map[string]func() ([]*store.SearchResult, error){
+				"vector": func() ([]*store.SearchResult, error) {
+					return s.SearchChunksVectorWithScore(ctx, vector(1, 0), tt.opts, 2)
+				},
+				"hybrid": func() ([]*store.SearchResult, error) {
+					return s.SearchChunksHybrid(ctx, vector(1, 0), "errors", tt.opts, 2)
+				},
+			}
+			for name, search := range searches {
+				results, err := search()
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("%s search error = %v, wantErr %v", name, err, tt.wantErr)
+				}
+				if tt.wantErr {
+					continue
+				}
+
+				got := make([]int64, len(results))
+				for i, r := range results {
+					got[i] = r.Chunk.DocumentID
+				}
+				if len(got) != len(tt.want) || got[0] != tt.want[0] || got[1] != tt.want[1] {
+					t.Errorf("%s search documents = %v, want %v", name, got, tt.want)
+				}
+			}
+		})
+	}
+
+	// A cap can leave fewer results than the limit
+	results, err := s.SearchChunksVectorWithScore(ctx, vector(1, 0), store.SearchOptions{MaxPerDocument: 1}, 10)
+	if err != nil {
+		t.Fatalf("SearchChunksVectorWithScore() error = %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("SearchChunksVectorWithScore() with cap = %d results, want 2", len(results))
+	}
+}
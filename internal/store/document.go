@@ -0,0 +1,179 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DocumentRef identifies a document, either by ID or by source and path.
+type DocumentRef struct {
+	ID       int64  // Document ID; if set, the other fields are ignored
+	Language string // Language name; only needed if several languages have the source
+	Source   string // Source name
+	Path     string // Document path within the source
+}
+
+// FullDocument is a document reassembled from its chunks.
+type FullDocument struct {
+	Document     *Document
+	Source       *Source
+	Language     string
+	Introduction string // Body of a document without headings, if it was short enough to index
+	Sections     []*Section
+	URL          string // Web permalink to the document, or the source URL
+}
+
+// Section is a section of a document, reassembled from the chunks it was
+// split into.
+type Section struct {
+	Title       string
+	Anchor      string   // Anchor of the heading; empty for documents indexed before anchors were recorded
+	Level       int      // Heading level, 1 for a top-level heading; 0 if unknown
+	Breadcrumbs []string // Headings from the document title down to this section
+	Content     string
+	ChunkIDs    []int64
+	URL         string // Web permalink to the section, or the source URL
+}
+
+// Section returns the section with the given anchor followed by its
+// subsections, or nil if the document has no such section.
+func (d *FullDocument) Section(anchor string) []*Section {
+	for i, sec := range d.Sections {
+		if sec.Anchor != anchor || anchor == "" {
+			continue
+		}
+		end := i + 1
+		for end < len(d.Sections) && sec.Level > 0 && d.Sections[end].Level > sec.Level {
+			end++
+		}
+		return d.Sections[i:end]
+	}
+	return nil
+}
+
+// GetDocument returns a document with its sections in document order,
+// reassembled from its chunks. Sections split into paragraphs are joined
+// back together; text between paragraph and sentence splits is normalised
+// to blank lines.
+func (s *Store) GetDocument(ctx context.Context, ref DocumentRef) (*FullDocument, error) {
+	id := ref.ID
+	if id == 0 {
+		var err error
+		if id, err = s.documentID(ctx, ref); err != nil {
+			return nil, err
+		}
+	}
+
+	doc, err := s.documentByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var src Source
+	var language string
+	err = s.db.QueryRowContext(ctx, `
+		SELECT s.id, s.language_id, s.name, s.type, s.url, s.tier, s.revision, l.name
+		FROM sources s
+		JOIN languages l ON s.language_id = l.id
+		WHERE s.id = ?
+	`, doc.SourceID).Scan(&src.ID, &src.LanguageID, &src.Name, &src.Type, &src.URL, &src.Tier, &src.Revision, &language)
+	if err != nil {
+		return nil, fmt.Errorf("query document source: %w", err)
+	}
+
+	chunks, err := s.documentChunks(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	full := &FullDocument{
+		Document: doc,
+		Source:   &src,
+		Language: language,
+		URL:      Permalink(src.URL, src.Revision, doc.Path, ""),
+	}
+	var current *Section
+	for _, c := range chunks {
+		if c.Level == "summary" {
+			// The summary is the title followed by the first section's text
+			// if it is short
+			full.Introduction = strings.TrimSpace(strings.TrimPrefix(c.Content, c.Title))
+			continue
+		}
+
+		// Paragraphs of one section are consecutive and share its heading
+		crumbs := breadcrumbs(doc, c)
+		if current != nil && c.Level == "paragraph" && current.Title == c.Title &&
+			current.Anchor == c.Anchor && slices.Equal(current.Breadcrumbs, crumbs) {
+			current.Content += "\n\n" + c.Content
+			current.ChunkIDs = append(current.ChunkIDs, c.ID)
+			continue
+		}
+
+		current = &Section{
+			Title:       c.Title,
+			Anchor:      c.Anchor,
+			Breadcrumbs: crumbs,
+			Content:     c.Content,
+			ChunkIDs:    []int64{c.ID},
+			URL:         Permalink(src.URL, src.Revision, doc.Path, c.Anchor),
+		}
+		if len(c.Breadcrumbs) > 0 {
+			current.Level = len(c.Breadcrumbs)
+		}
+		full.Sections = append(full.Sections, current)
+		if c.Level != "paragraph" {
+			// A whole section never continues into the next chunk
+			current = nil
+		}
+	}
+
+	// The parser drops text before the first heading, so the summary only
+	// adds something for a document without headings
+	if len(full.Sections) > 0 && full.Sections[0].Content == full.Introduction {
+		full.Introduction = ""
+	}
+	return full, nil
+}
+
+// documentID returns the ID of the document ref names by source and path.
+func (s *Store) documentID(ctx context.Context, ref DocumentRef) (int64, error) {
+	name := ref.Source + "/" + ref.Path
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT d.id, l.name
+		FROM documents d
+		JOIN sources s ON d.source_id = s.id
+		JOIN languages l ON s.language_id = l.id
+		WHERE s.name = ? AND d.path = ? AND (? = '' OR l.name = ?)
+	`, ref.Source, ref.Path, ref.Language, ref.Language)
+	if err != nil {
+		return 0, fmt.Errorf("query document: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	var languages []string
+	for rows.Next() {
+		var id int64
+		var language string
+		if err := rows.Scan(&id, &language); err != nil {
+			return 0, fmt.Errorf("scan document: %w", err)
+		}
+		ids = append(ids, id)
+		languages = append(languages, language)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("iterate documents: %w", err)
+	}
+
+	switch len(ids) {
+	case 0:
+		return 0, fmt.Errorf("document %q: %w", name, ErrNotFound)
+	case 1:
+		return ids[0], nil
+	default:
+		return 0, fmt.Errorf("document %q exists for several languages (%s); specify one", name, strings.Join(languages, ", "))
+	}
+}
@@ -0,0 +1,160 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jamesainslie/grimoire/internal/chunk"
+	"github.com/jamesainslie/grimoire/internal/parse"
+	"github.com/jamesainslie/grimoire/internal/store"
+)
+
+func TestStore_GetDocument(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	goLang, _ := s.CreateLanguage(ctx, "go", "Go")
+	rustLang, _ := s.CreateLanguage(ctx, "rust", "Rust")
+	src, _ := s.CreateSource(ctx, goLang.ID, "guide", "git", "https://github.com/uber-go/guide")
+	if err := s.SetSourceRevision(ctx, src.ID, "abc123"); err != nil {
+		t.Fatalf("SetSourceRevision() error = %v", err)
+	}
+	rustSrc, _ := s.CreateSource(ctx, rustLang.ID, "guide", "git", "https://github.com/rust-lang/api-guidelines")
+
+	// Small chunks split the Wrapping section into its two paragraphs
+	doc, err := s.ReplaceDocument(ctx, &store.Document{SourceID: src.ID, Path: "style.md", Title: "Style"}, chunkMarkdown(t, `# Style
+
+Conventions for Go code at Uber.
+
+## Errors
+
+Errors are values.
+
+### Wrapping
+
+Wrap errors with %w.
+
+Add context without repeating it.
+
+## Panics
+
+Do not panic.
+`, 10))
+	if err != nil {
+		t.Fatalf("ReplaceDocument() error = %v", err)
+	}
+	rustDoc, err := s.ReplaceDocument(ctx, &store.Document{SourceID: rustSrc.ID, Path: "style.md", Title: "Rust style"},
+		chunkMarkdown(t, "Follow the Rust API guidelines.\n", 512))
+	if err != nil {
+		t.Fatalf("ReplaceDocument() error = %v", err)
+	}
+
+	full, err := s.GetDocument(ctx, store.DocumentRef{ID: doc.ID})
+	if err != nil {
+		t.Fatalf("GetDocument(ID) error = %v", err)
+	}
+	if full.Document.Path != "style.md" || full.Source.Name != "guide" || full.Language != "go" {
+		t.Errorf("GetDocument() = %s/%s (%s), want guide/style.md (go)", full.Source.Name, full.Document.Path, full.Language)
+	}
+	// The summary repeats the first section, which the document shows once
+	if full.Introduction != "" {
+		t.Errorf("Introduction = %q, want none", full.Introduction)
+	}
+
+	var anchors []string
+	for _, sec := range full.Sections {
+		anchors = append(anchors, sec.Anchor)
+	}
+	if want := []string{"style", "errors", "wrapping", "panics"}; !slices.Equal(anchors, want) {
+		t.Fatalf("section anchors = %v, want %v", anchors, want)
+	}
+	if want := "Conventions for Go code at Uber."; full.Sections[0].Content != want {
+		t.Errorf("style Content = %q, want %q", full.Sections[0].Content, want)
+	}
+	wrapping := full.Sections[2]
+	if want := "Wrap errors with %w.\n\nAdd context without repeating it."; wrapping.Content != want {
+		t.Errorf("wrapping Content = %q, want %q", wrapping.Content, want)
+	}
+	if len(wrapping.ChunkIDs) != 2 || wrapping.Level != 3 {
+		t.Errorf("wrapping = %d chunks at level %d, want 2 at level 3", len(wrapping.ChunkIDs), wrapping.Level)
+	}
+	if want := "https://github.com/uber-go/guide/blob/abc123/style.md#wrapping"; wrapping.URL != want {
+		t.Errorf("wrapping URL = %q, want %q", wrapping.URL, want)
+	}
+
+	tests := []struct {
+		anchor string
+		want   []string
+	}{
+		{anchor: "style", want: []string{"style", "errors", "wrapping", "panics"}},
+		{anchor: "errors", want: []string{"errors", "wrapping"}},
+		{anchor: "wrapping", want: []string{"wrapping"}},
+		{anchor: "panics", want: []string{"panics"}},
+		{anchor: "missing"},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, sec := range full.Section(tt.anchor) {
+			got = append(got, sec.Anchor)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Section(%q) = %v, want %v", tt.anchor, got, tt.want)
+		}
+	}
+
+	// A document without headings is only indexed in its summary
+	rust, err := s.GetDocument(ctx, store.DocumentRef{ID: rustDoc.ID})
+	if err != nil {
+		t.Fatalf("GetDocument(rust) error = %v", err)
+	}
+	if rust.Introduction != "Follow the Rust API guidelines." || len(rust.Sections) != 0 {
+		t.Errorf("GetDocument(rust) = introduction %q and %d sections, want the text and none", rust.Introduction, len(rust.Sections))
+	}
+
+	byPath, err := s.GetDocument(ctx, store.DocumentRef{Language: "go", Source: "guide", Path: "style.md"})
+	if err != nil || byPath.Document.ID != doc.ID {
+		t.Errorf("GetDocument(go guide/style.md) = %v, %v; want document %d", byPath, err, doc.ID)
+	}
+	_, err = s.GetDocument(ctx, store.DocumentRef{Source: "guide", Path: "style.md"})
+	if err == nil || !strings.Contains(err.Error(), "several languages") {
+		t.Errorf("GetDocument(ambiguous) error = %v, want several languages", err)
+	}
+	_, err = s.GetDocument(ctx, store.DocumentRef{Source: "guide", Path: "missing.md"})
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetDocument(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+// chunkMarkdown parses and chunks markdown as ingest does, with chunks of at
+// most maxTokens.
+func chunkMarkdown(t *testing.T, markdown string, maxTokens int) []store.NewChunk {
+	t.Helper()
+
+	doc, err := parse.Parse([]byte(markdown))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	chunks, err := chunk.NewChunker(maxTokens).Chunk(doc)
+	if err != nil {
+		t.Fatalf("Chunk() error = %v", err)
+	}
+
+	newChunks := make([]store.NewChunk, len(chunks))
+	for i, c := range chunks {
+		newChunks[i] = store.NewChunk{
+			ParentIndex: c.ParentIndex,
+			Level:       c.Level,
+			Title:       c.Title,
+			Content:     c.Content,
+			TokenCount:  c.TokenCount,
+			Breadcrumbs: c.Breadcrumbs,
+			Anchor:      c.Anchor,
+		}
+	}
+	return newChunks
+}
@@ -0,0 +1,182 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SearchOptions restricts which chunks a search may return and tunes their
+// ranking. The zero value searches everything with the default ranking. All
+// set filter fields must match.
+type SearchOptions struct {
+	LanguageID     int64    // Restrict to one language; 0 searches all
+	Sources        []string // Restrict to sources with these names
+	ExcludeSources []string // Skip sources with these names
+	Tiers          []int    // Restrict to sources with these tiers
+	Levels         []string // Restrict to chunk levels, e.g. "section"
+	PathPrefix     string   // Restrict to documents whose path starts with this
+	CodeOnly       bool     // Restrict to chunks containing a fenced code block
+
+	// AdvancedQuery passes the text query to FTS5 unchanged, allowing its
+	// query syntax (AND, NEAR, prefix*, column filters). By default the query
+	// is translated with FTSQuery.
+	AdvancedQuery bool
+
+	// Diversity re-ranks results by Maximal Marginal Relevance over their
+	// stored vectors, trading relevance for variety from 0 (relevance
+	// alone) to 1, so near-duplicate chunks do not crowd out the rest.
+	// MaxPerDocument caps the results taken from one document; 0 is
+	// unlimited.
+	Diversity      float64
+	MaxPerDocument int
+
+	Ranking Ranking // Hybrid search ranking
+}
+
+// validate reports options that cannot be used.
+func (o SearchOptions) validate() error {
+	if err := o.Ranking.Validate(); err != nil {
+		return err
+	}
+	switch {
+	case o.Diversity < 0 || o.Diversity > 1:
+		return fmt.Errorf("diversity must be between 0 and 1, got %v", o.Diversity)
+	case o.MaxPerDocument < 0:
+		return fmt.Errorf("max results per document must not be negative, got %d", o.MaxPerDocument)
+	}
+	return nil
+}
+
+// searchFilter is a SearchOptions with source names resolved to IDs.
+type searchFilter struct {
+	opts       SearchOptions
+	sourceIDs  []int64
+	excludeIDs []int64
+}
+
+// Filter columns, named after the vector table's metadata columns.
+var (
+	// vectorFilterColumns compares chunks_vec metadata columns directly.
+	vectorFilterColumns = map[string]string{
+		"language_id": "language_id",
+		"source_id":   "source_id",
+		"tier":        "tier",
+		"level":       "level",
+		"path":        "path",
+		"has_code":    "has_code",
+	}
+	// chunkFilterColumns compares chunk c joined to its document d and source s.
+	chunkFilterColumns = map[string]string{
+		"language_id": "s.language_id",
+		"source_id":   "s.id",
+		"tier":        "s.tier",
+		"level":       "c.level",
+		"path":        "d.path",
+		"has_code":    hasCodeExpr,
+	}
+)
+
+// searchFilter resolves opts for use in queries. It returns ErrNotFound if
+// a named source does not exist.
+func (s *Store) searchFilter(ctx context.Context, opts SearchOptions) (*searchFilter, error) {
+	f := &searchFilter{opts: opts}
+	var err error
+	if f.sourceIDs, err = s.sourceIDs(ctx, opts.LanguageID, opts.Sources); err != nil {
+		return nil, err
+	}
+	if f.excludeIDs, err = s.sourceIDs(ctx, opts.LanguageID, opts.ExcludeSources); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// sourceIDs returns the IDs of the named sources, within languageID unless
+// it is 0. A name may match one source per language.
+func (s *Store) sourceIDs(ctx context.Context, languageID int64, names []string) ([]int64, error) {
+	var ids []int64
+	for _, name := range names {
+		query := "SELECT id FROM sources WHERE name = ?"
+		args := []any{name}
+		if languageID != 0 {
+			query += " AND language_id = ?"
+			args = append(args, languageID)
+		}
+
+		rows, err := s.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("look up source %q: %w", name, err)
+		}
+		found := false
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("scan source: %w", err)
+			}
+			ids = append(ids, id)
+			found = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("iterate sources: %w", err)
+		}
+		if !found {
+			return nil, fmt.Errorf("source %q: %w", name, ErrNotFound)
+		}
+	}
+	return ids, nil
+}
+
+// conditions returns the filter as SQL conditions to be joined with AND,
+// and their arguments. columns maps filter column names to SQL expressions.
+func (f *searchFilter) conditions(columns map[string]string) ([]string, []any) {
+	var conds []string
+	var args []any
+
+	if f.opts.LanguageID != 0 {
+		conds = append(conds, columns["language_id"]+" = ?")
+		args = append(args, f.opts.LanguageID)
+	}
+	if len(f.sourceIDs) > 0 {
+		conds = append(conds, columns["source_id"]+" IN ("+placeholders(len(f.sourceIDs))+")")
+		for _, id := range f.sourceIDs {
+			args = append(args, id)
+		}
+	}
+	if len(f.excludeIDs) > 0 {
+		conds = append(conds, columns["source_id"]+" NOT IN ("+placeholders(len(f.excludeIDs))+")")
+		for _, id := range f.excludeIDs {
+			args = append(args, id)
+		}
+	}
+	if len(f.opts.Tiers) > 0 {
+		conds = append(conds, columns["tier"]+" IN ("+placeholders(len(f.opts.Tiers))+")")
+		for _, tier := range f.opts.Tiers {
+			args = append(args, tier)
+		}
+	}
+	if len(f.opts.Levels) > 0 {
+		conds = append(conds, columns["level"]+" IN ("+placeholders(len(f.opts.Levels))+")")
+		for _, level := range f.opts.Levels {
+			args = append(args, level)
+		}
+	}
+	if f.opts.PathPrefix != "" {
+		// A range rather than LIKE, which sqlite-vec metadata columns do not
+		// support. 0xFF never occurs in UTF-8, so it sorts after every path
+		// with the prefix.
+		conds = append(conds, columns["path"]+" >= ?", columns["path"]+" < ?")
+		args = append(args, f.opts.PathPrefix, f.opts.PathPrefix+"\xff")
+	}
+	if f.opts.CodeOnly {
+		conds = append(conds, columns["has_code"]+" = 1")
+	}
+
+	return conds, args
+}
+
+// placeholders returns n comma-separated SQL parameter placeholders.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
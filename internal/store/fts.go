@@ -0,0 +1,76 @@
+package store
+
+import (
+	"strings"
+	"unicode"
+)
+
+// termJoiners are punctuation characters kept inside a term when they join
+// letters or digits, as in "errors.Is", "io/fs" or "well-known". The FTS5
+// tokenizer still splits on them, so such a term becomes a phrase query
+// matching the parts in order.
+const termJoiners = ".-/:_"
+
+// stopwords are dropped from natural-language queries. They match almost
+// every chunk, so ORing them in only adds noise to BM25 ranking.
+var stopwords = map[string]bool{
+	"a": true, "about": true, "an": true, "and": true, "are": true, "as": true,
+	"at": true, "be": true, "between": true, "by": true, "can": true,
+	"could": true, "do": true, "does": true, "for": true, "from": true,
+	"how": true, "i": true, "if": true, "in": true, "is": true, "it": true,
+	"its": true, "me": true, "my": true, "of": true, "on": true, "or": true,
+	"s": true, "should": true, "t": true, "than": true, "that": true,
+	"the": true, "there": true, "this": true, "to": true, "versus": true,
+	"vs": true, "was": true, "we": true, "what": true, "when": true,
+	"where": true, "which": true, "who": true, "why": true, "will": true,
+	"with": true, "would": true, "you": true, "your": true,
+}
+
+// FTSQuery translates natural-language text into an FTS5 query that is
+// always syntactically valid: each distinct term is quoted and the terms are
+// ORed together, so that BM25 ranks chunks matching more of them higher.
+// Stopwords are dropped unless nothing else remains. It returns "" if text
+// contains no searchable terms.
+//
+// Quoting disables FTS5 operators, so input such as `what's "context.Context"?`
+// or `-race flag` is searched for literally rather than rejected as a syntax
+// error.
+func FTSQuery(text string) string {
+	var terms, stops []string
+	seen := make(map[string]bool)
+
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(termJoiners, r)
+	})
+	for _, field := range fields {
+		term := strings.Trim(field, termJoiners)
+		key := strings.ToLower(term)
+		if term == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		// Terms contain only letters, digits and joiners, so quoting them
+		// needs no escaping
+		quoted := `"` + term + `"`
+		if stopwords[key] {
+			stops = append(stops, quoted)
+			continue
+		}
+		terms = append(terms, quoted)
+	}
+
+	if len(terms) == 0 {
+		terms = stops
+	}
+	return strings.Join(terms, " OR ")
+}
+
+// ftsMatch returns the FTS5 expression to search for a text query: the query
+// itself in advanced mode, otherwise its FTSQuery translation.
+func ftsMatch(query string, opts SearchOptions) string {
+	if opts.AdvancedQuery {
+		return query
+	}
+	return FTSQuery(query)
+}
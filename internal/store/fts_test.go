@@ -0,0 +1,94 @@
+package store_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jamesainslie/grimoire/internal/store"
+)
+
+func TestFTSQuery(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "goroutines", want: `"goroutines"`},
+		{input: `what's "context.Context"?`, want: `"context.Context"`},
+		{input: "errors.Is vs errors.As", want: `"errors.Is" OR "errors.As"`},
+		{input: "-race flag: go test *", want: `"race" OR "flag" OR "go" OR "test"`},
+		{input: "Mutex mutex MUTEX", want: `"Mutex"`},
+		{input: "io/fs and well-known paths", want: `"io/fs" OR "well-known" OR "paths"`},
+		{input: "what is it", want: `"what" OR "is" OR "it"`},
+		{input: `"") OR NEAR(`, want: `"NEAR"`},
+		{input: "?!", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+
+			if got := store.FTSQuery(tt.input); got != tt.want {
+				t.Errorf("FTSQuery(%q) = %s, want %s", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStore_SearchChunks_QuerySyntax(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	lang, _ := s.CreateLanguage(ctx, "go", "Go")
+	src, _ := s.CreateSource(ctx, lang.ID, "go-wiki", "git", "https://github.com/golang/wiki")
+	embedding := make([]float32, 1024)
+	embedding[0] = 1
+	_, err := s.ReplaceDocument(ctx, &store.Document{SourceID: src.ID, Path: "errors.md"}, []store.NewChunk{{
+		Level:     "section",
+		Title:     "Wrapping errors",
+		Content:   strings.Repeat("Use errors.Is to compare against a sentinel and errors.As to extract a type. ", 2),
+		Embedding: embedding,
+	}})
+	if err != nil {
+		t.Fatalf("ReplaceDocument() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		opts    store.SearchOptions
+		wantFTS int
+		wantErr bool
+	}{
+		{name: "natural language", query: "errors.Is vs errors.As", wantFTS: 1},
+		{name: "operators", query: `what's "errors.Is"? -wrap: sentinel*`, wantFTS: 1},
+		{name: "advanced", query: "sentinel AND extract", opts: store.SearchOptions{AdvancedQuery: true}, wantFTS: 1},
+		{name: "advanced prefix", query: "senti*", opts: store.SearchOptions{AdvancedQuery: true}, wantFTS: 1},
+		{name: "advanced syntax error", query: `errors.Is"`, opts: store.SearchOptions{AdvancedQuery: true}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks, err := s.SearchChunksFTS(ctx, tt.query, tt.opts, 10)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SearchChunksFTS(%q) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			}
+			if len(chunks) != tt.wantFTS {
+				t.Errorf("SearchChunksFTS(%q) = %d results, want %d", tt.query, len(chunks), tt.wantFTS)
+			}
+
+			// Hybrid search never fails on the text query
+			results, err := s.SearchChunksHybrid(ctx, embedding, tt.query, tt.opts, 10)
+			if err != nil {
+				t.Fatalf("SearchChunksHybrid(%q) error = %v", tt.query, err)
+			}
+			if len(results) != 1 {
+				t.Errorf("SearchChunksHybrid(%q) = %d results, want 1", tt.query, len(results))
+			}
+		})
+	}
+}
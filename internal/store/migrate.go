@@ -0,0 +1,333 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrSchemaTooNew is returned when the database schema version is newer than
+// the latest migration known to this binary.
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary supports")
+
+// Migration describes a single versioned schema change.
+type Migration struct {
+	Version int
+	Name    string
+}
+
+// migration is a Migration together with the function that applies it.
+type migration struct {
+	Migration
+	up func(ctx context.Context, tx *sql.Tx) error
+}
+
+// migrations lists every schema change in the order it must be applied.
+// Versions must be contiguous and start at 1. Never edit a migration that has
+// shipped; add a new one instead.
+var migrations = []migration{
+	{
+		Migration: Migration{Version: 1, Name: "initial schema"},
+		// Uses IF NOT EXISTS so databases created before schema versioning
+		// existed are adopted without error.
+		up: execStatements(
+			`CREATE TABLE IF NOT EXISTS languages (
+				id INTEGER PRIMARY KEY,
+				name TEXT NOT NULL UNIQUE,
+				display_name TEXT NOT NULL
+			)`,
+			`CREATE TABLE IF NOT EXISTS sources (
+				id INTEGER PRIMARY KEY,
+				language_id INTEGER NOT NULL REFERENCES languages(id),
+				name TEXT NOT NULL,
+				type TEXT NOT NULL,
+				url TEXT NOT NULL,
+				UNIQUE(language_id, name)
+			)`,
+			`CREATE TABLE IF NOT EXISTS documents (
+				id INTEGER PRIMARY KEY,
+				source_id INTEGER NOT NULL REFERENCES sources(id),
+				path TEXT NOT NULL,
+				title TEXT,
+				UNIQUE(source_id, path)
+			)`,
+			`CREATE TABLE IF NOT EXISTS chunks (
+				id INTEGER PRIMARY KEY,
+				document_id INTEGER NOT NULL REFERENCES documents(id),
+				parent_chunk_id INTEGER REFERENCES chunks(id),
+				level TEXT NOT NULL,
+				title TEXT,
+				content TEXT NOT NULL,
+				token_count INTEGER
+			)`,
+			`CREATE VIRTUAL TABLE IF NOT EXISTS chunks_fts USING fts5(
+				title,
+				content,
+				content='chunks',
+				content_rowid='id'
+			)`,
+			`CREATE TRIGGER IF NOT EXISTS chunks_ai AFTER INSERT ON chunks BEGIN
+				INSERT INTO chunks_fts(rowid, title, content)
+				VALUES (new.id, new.title, new.content);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS chunks_ad AFTER DELETE ON chunks BEGIN
+				INSERT INTO chunks_fts(chunks_fts, rowid, title, content)
+				VALUES ('delete', old.id, old.title, old.content);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS chunks_au AFTER UPDATE ON chunks BEGIN
+				INSERT INTO chunks_fts(chunks_fts, rowid, title, content)
+				VALUES ('delete', old.id, old.title, old.content);
+				INSERT INTO chunks_fts(rowid, title, content)
+				VALUES (new.id, new.title, new.content);
+			END`,
+			`CREATE VIRTUAL TABLE IF NOT EXISTS chunks_vec USING vec0(
+				chunk_id INTEGER PRIMARY KEY,
+				embedding FLOAT[1024]
+			)`,
+		),
+	},
+	{
+		Migration: Migration{Version: 2, Name: "document content hash"},
+		up: execStatements(
+			`ALTER TABLE documents ADD COLUMN content_hash TEXT`,
+			`ALTER TABLE documents ADD COLUMN fetched_at DATETIME`,
+		),
+	},
+	{
+		Migration: Migration{Version: 3, Name: "cascade chunk deletes to vectors"},
+		up: execStatements(
+			// Remove orphaned vectors left behind before this trigger existed
+			`DELETE FROM chunks_vec WHERE chunk_id NOT IN (SELECT id FROM chunks)`,
+			`CREATE TRIGGER IF NOT EXISTS chunks_vec_ad AFTER DELETE ON chunks BEGIN
+				DELETE FROM chunks_vec WHERE chunk_id = old.id;
+			END`,
+		),
+	},
+	{
+		Migration: Migration{Version: 4, Name: "embedding model registry"},
+		up: execStatements(
+			`CREATE TABLE IF NOT EXISTS metadata (
+				key TEXT PRIMARY KEY,
+				value TEXT NOT NULL
+			)`,
+			// Databases with vectors predate configurable models and were
+			// always built with snowflake-arctic-embed:l at 1024 dimensions.
+			`INSERT INTO metadata (key, value)
+				SELECT 'embedding_model', 'snowflake-arctic-embed:l'
+				WHERE EXISTS (SELECT 1 FROM chunks_vec)`,
+			`INSERT INTO metadata (key, value)
+				SELECT 'embedding_dimensions', '1024'
+				WHERE EXISTS (SELECT 1 FROM chunks_vec)`,
+		),
+	},
+	{
+		Migration: Migration{Version: 5, Name: "vector search metadata"},
+		up:        addVectorMetadata,
+	},
+	{
+		Migration: Migration{Version: 6, Name: "vector path and code metadata"},
+		up:        addVectorPathMetadata,
+	},
+	{
+		Migration: Migration{Version: 7, Name: "source tiers"},
+		up:        addSourceTiers,
+	},
+	{
+		Migration: Migration{Version: 8, Name: "code identifier index"},
+		up:        createSymbolIndex,
+	},
+	{
+		Migration: Migration{Version: 9, Name: "chunk breadcrumbs"},
+		up:        execStatements(`ALTER TABLE chunks ADD COLUMN breadcrumbs TEXT NOT NULL DEFAULT ''`),
+	},
+	{
+		Migration: Migration{Version: 10, Name: "citation metadata"},
+		up: execStatements(
+			`ALTER TABLE chunks ADD COLUMN anchor TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE sources ADD COLUMN revision TEXT NOT NULL DEFAULT ''`,
+		),
+	},
+	{
+		Migration: Migration{Version: 11, Name: "topic index"},
+		up:        createTopicIndex,
+	},
+	{
+		// Ingest skips unchanged files, so documents indexed before the
+		// breadcrumbs, anchors and headings above were recorded would never
+		// get them. Clearing every content hash re-indexes each document
+		// once on the next ingest.
+		Migration: Migration{Version: 12, Name: "re-index documents"},
+		up:        execStatements(`UPDATE documents SET content_hash = NULL`),
+	},
+}
+
+// LatestSchemaVersion returns the schema version this binary migrates to.
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// execStatements returns a migration function that executes each statement in order.
+func execStatements(stmts ...string) func(ctx context.Context, tx *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		for _, stmt := range stmts {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// ensureVersionTable creates the schema_version bookkeeping table.
+func (s *Store) ensureVersionTable(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_version (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("create schema_version table: %w", err)
+	}
+	return nil
+}
+
+// SchemaVersion returns the highest migration version applied to the database.
+// A database that has never been migrated reports version 0. It does not
+// write to the database.
+func (s *Store) SchemaVersion(ctx context.Context) (int, error) {
+	return schemaVersion(ctx, s.db)
+}
+
+// queryRower is satisfied by both *sql.DB and *sql.Tx.
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// schemaVersion returns the highest migration version recorded in
+// schema_version, or 0 if the table does not exist yet.
+func schemaVersion(ctx context.Context, q queryRower) (int, error) {
+	var tables int
+	err := q.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'",
+	).Scan(&tables)
+	if err != nil {
+		return 0, fmt.Errorf("query schema version: %w", err)
+	}
+	if tables == 0 {
+		return 0, nil
+	}
+
+	var version int
+	err = q.QueryRowContext(ctx,
+		"SELECT COALESCE(MAX(version), 0) FROM schema_version",
+	).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("query schema version: %w", err)
+	}
+	return version, nil
+}
+
+// PendingMigrations returns the migrations that have not yet been applied,
+// in the order they would run. It returns ErrSchemaTooNew if the database is
+// ahead of this binary.
+func (s *Store) PendingMigrations(ctx context.Context) ([]Migration, error) {
+	pending, err := s.pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Migration, len(pending))
+	for i, m := range pending {
+		result[i] = m.Migration
+	}
+	return result, nil
+}
+
+// Migrate applies all pending migrations and returns the ones it applied.
+// Each migration runs in its own transaction together with the update to
+// schema_version, so an interrupted upgrade leaves the database at the last
+// fully applied version.
+func (s *Store) Migrate(ctx context.Context) ([]Migration, error) {
+	pending, err := s.pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+	if err := s.ensureVersionTable(ctx); err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, m := range pending {
+		ok, err := s.apply(ctx, m)
+		if err != nil {
+			return applied, fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		if ok {
+			applied = append(applied, m.Migration)
+		}
+	}
+
+	return applied, nil
+}
+
+// pending returns the unapplied migrations.
+func (s *Store) pending(ctx context.Context) ([]migration, error) {
+	current, err := s.SchemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if latest := LatestSchemaVersion(); current > latest {
+		return nil, fmt.Errorf("database version %d, binary supports %d: %w", current, latest, ErrSchemaTooNew)
+	}
+
+	var pending []migration
+	for _, m := range migrations {
+		if m.Version > current {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// apply runs a single migration and records it in schema_version. It
+// reports false without running the migration if another process applied it
+// first; see Open for how the transaction excludes other writers.
+func (s *Store) apply(ctx context.Context, m migration) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := schemaVersion(ctx, tx)
+	if err != nil {
+		return false, err
+	}
+	if current >= m.Version {
+		return false, nil
+	}
+
+	if err := m.up(ctx, tx); err != nil {
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO schema_version (version, name) VALUES (?, ?)",
+		m.Version, m.Name,
+	)
+	if err != nil {
+		return false, fmt.Errorf("record version: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit: %w", err)
+	}
+	return true, nil
+}
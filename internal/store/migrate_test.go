@@ -0,0 +1,285 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/jamesainslie/grimoire/internal/store"
+)
+
+func TestStore_Migrate(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	version, err := s.SchemaVersion(ctx)
+	if err != nil {
+		t.Fatalf("SchemaVersion() error = %v", err)
+	}
+	if version != store.LatestSchemaVersion() {
+		t.Errorf("SchemaVersion() = %d, want %d", version, store.LatestSchemaVersion())
+	}
+
+	pending, err := s.PendingMigrations(ctx)
+	if err != nil {
+		t.Fatalf("PendingMigrations() error = %v", err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("PendingMigrations() = %d, want 0", len(pending))
+	}
+
+	// Migrating again is a no-op
+	applied, err := s.Migrate(ctx)
+	if err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("Migrate() applied %d migrations, want 0", len(applied))
+	}
+}
+
+func TestOpen_PendingMigrations(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "grimoire.db")
+
+	s, err := store.Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+
+	pending, err := s.PendingMigrations(ctx)
+	if err != nil {
+		t.Fatalf("PendingMigrations() error = %v", err)
+	}
+	if len(pending) != store.LatestSchemaVersion() {
+		t.Fatalf("PendingMigrations() = %d, want %d", len(pending), store.LatestSchemaVersion())
+	}
+	for i, m := range pending {
+		if m.Version != i+1 {
+			t.Errorf("pending[%d].Version = %d, want %d", i, m.Version, i+1)
+		}
+	}
+
+	// Listing pending migrations must not apply them
+	version, err := s.SchemaVersion(ctx)
+	if err != nil {
+		t.Fatalf("SchemaVersion() error = %v", err)
+	}
+	if version != 0 {
+		t.Errorf("SchemaVersion() after dry run = %d, want 0", version)
+	}
+
+	// Nor write to the database at all
+	raw, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer raw.Close()
+	var tables int
+	if err := raw.QueryRow("SELECT COUNT(*) FROM sqlite_master").Scan(&tables); err != nil {
+		t.Fatalf("count tables: %v", err)
+	}
+	if tables != 0 {
+		t.Errorf("database has %d schema objects after dry run, want 0", tables)
+	}
+}
+
+func TestNew_ConcurrentMigration(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "grimoire.db")
+
+	// Processes starting together on a new database must not both migrate it
+	const opens = 4
+	errs := make(chan error, opens)
+	for range opens {
+		go func() {
+			s, err := store.New(path)
+			if err == nil {
+				s.Close()
+			}
+			errs <- err
+		}()
+	}
+	for range opens {
+		if err := <-errs; err != nil {
+			t.Errorf("New() error = %v", err)
+		}
+	}
+
+	s, err := store.Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+	version, err := s.SchemaVersion(context.Background())
+	if err != nil || version != store.LatestSchemaVersion() {
+		t.Errorf("SchemaVersion() = %d, %v; want %d", version, err, store.LatestSchemaVersion())
+	}
+}
+
+func TestNew_AdoptsLegacyDatabase(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "grimoire.db")
+
+	// A database created before schema versioning existed
+	raw, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	_, err = raw.Exec(`
+		CREATE TABLE languages (id INTEGER PRIMARY KEY, name TEXT NOT NULL UNIQUE, display_name TEXT NOT NULL);
+		INSERT INTO languages (name, display_name) VALUES ('go', 'Go');
+	`)
+	raw.Close()
+	if err != nil {
+		t.Fatalf("create legacy schema: %v", err)
+	}
+
+	s, err := store.New(path)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer s.Close()
+
+	lang, err := s.GetLanguage(ctx, "go")
+	if err != nil {
+		t.Fatalf("GetLanguage() error = %v", err)
+	}
+	if lang.DisplayName != "Go" {
+		t.Errorf("GetLanguage() DisplayName = %v, want Go", lang.DisplayName)
+	}
+}
+
+func TestNew_KeepsRegisteredDimensions(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "grimoire.db")
+
+	// A version 4 database built with a 768-dimension model whose vectors
+	// have all been removed
+	createVersion4Database(t, path, `
+		INSERT INTO metadata (key, value) VALUES ('embedding_model', 'nomic-embed-text'), ('embedding_dimensions', '768');
+	`)
+
+	s, err := store.New(path)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer s.Close()
+
+	// Migrations rebuilding the vector table keep the registered dimension
+	if err := s.RegisterEmbeddingModel(ctx, "nomic-embed-text", 768); err != nil {
+		t.Fatalf("RegisterEmbeddingModel() error = %v", err)
+	}
+	lang, _ := s.CreateLanguage(ctx, "go", "Go")
+	src, _ := s.CreateSource(ctx, lang.ID, "go-wiki", "git", "https://github.com/golang/wiki")
+	doc, _ := s.CreateDocument(ctx, src.ID, "errors.md", "Errors")
+	chunk, err := s.CreateChunk(ctx, doc.ID, nil, "section", "Errors", "Errors are values.", 5)
+	if err != nil {
+		t.Fatalf("CreateChunk() error = %v", err)
+	}
+	if err := s.StoreEmbedding(ctx, chunk.ID, make([]float32, 768)); err != nil {
+		t.Errorf("StoreEmbedding(768 dimensions) error = %v", err)
+	}
+}
+
+func TestNew_ReindexesUpgradedDocuments(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "grimoire.db")
+	createVersion4Database(t, path, `
+		INSERT INTO languages (id, name, display_name) VALUES (1, 'go', 'Go');
+		INSERT INTO sources (id, language_id, name, type, url) VALUES (1, 1, 'go-wiki', 'git', 'https://github.com/golang/wiki');
+		INSERT INTO documents (source_id, path, title, content_hash) VALUES (1, 'errors.md', 'Errors', 'abc123');
+	`)
+
+	s, err := store.New(path)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer s.Close()
+
+	// Documents indexed without breadcrumbs, anchors and headings must not
+	// be skipped as unchanged by the next ingest
+	doc, err := s.GetDocumentByPath(ctx, 1, "errors.md")
+	if err != nil {
+		t.Fatalf("GetDocumentByPath() error = %v", err)
+	}
+	if doc.ContentHash != "" {
+		t.Errorf("ContentHash = %q after upgrade, want none", doc.ContentHash)
+	}
+}
+
+func TestNew_RefusesNewerSchema(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "grimoire.db")
+
+	s, err := store.New(path)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	s.Close()
+
+	raw, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	_, err = raw.Exec("INSERT INTO schema_version (version, name) VALUES (?, 'from the future')", store.LatestSchemaVersion()+1)
+	raw.Close()
+	if err != nil {
+		t.Fatalf("bump schema version: %v", err)
+	}
+
+	_, err = store.New(path)
+	if !errors.Is(err, store.ErrSchemaTooNew) {
+		t.Errorf("New() error = %v, want ErrSchemaTooNew", err)
+	}
+}
+
+// createVersion4Database creates a database at path with the schema of
+// version 4, whose vector table has 768 dimensions, and runs setup on it.
+func createVersion4Database(t *testing.T, path, setup string) {
+	t.Helper()
+
+	raw, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer raw.Close()
+
+	_, err = raw.Exec(`
+		CREATE TABLE schema_version (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP);
+		INSERT INTO schema_version (version, name) VALUES
+			(1, 'initial schema'), (2, 'document content hash'),
+			(3, 'cascade chunk deletes to vectors'), (4, 'embedding model registry');
+		CREATE TABLE languages (id INTEGER PRIMARY KEY, name TEXT NOT NULL UNIQUE, display_name TEXT NOT NULL);
+		CREATE TABLE sources (id INTEGER PRIMARY KEY, language_id INTEGER NOT NULL REFERENCES languages(id),
+			name TEXT NOT NULL, type TEXT NOT NULL, url TEXT NOT NULL, UNIQUE(language_id, name));
+		CREATE TABLE documents (id INTEGER PRIMARY KEY, source_id INTEGER NOT NULL REFERENCES sources(id),
+			path TEXT NOT NULL, title TEXT, content_hash TEXT, fetched_at DATETIME, UNIQUE(source_id, path));
+		CREATE TABLE chunks (id INTEGER PRIMARY KEY, document_id INTEGER NOT NULL REFERENCES documents(id),
+			parent_chunk_id INTEGER REFERENCES chunks(id), level TEXT NOT NULL, title TEXT, content TEXT NOT NULL, token_count INTEGER);
+		CREATE VIRTUAL TABLE chunks_fts USING fts5(title, content, content='chunks', content_rowid='id');
+		CREATE VIRTUAL TABLE chunks_vec USING vec0(chunk_id INTEGER PRIMARY KEY, embedding FLOAT[768]);
+		CREATE TABLE metadata (key TEXT PRIMARY KEY, value TEXT NOT NULL);
+	`)
+	if err != nil {
+		t.Fatalf("create version 4 schema: %v", err)
+	}
+	if _, err := raw.Exec(setup); err != nil {
+		t.Fatalf("set up version 4 database: %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ErrModelMismatch is returned when vectors from one embedding model are used
+// with a database built with a different model or dimension.
+var ErrModelMismatch = errors.New("embedding model mismatch")
+
+// Metadata keys recording the embedding model of the vector table.
+const (
+	metaEmbeddingModel      = "embedding_model"
+	metaEmbeddingDimensions = "embedding_dimensions"
+)
+
+// EmbeddingModel describes the model the database's vectors were built with.
+type EmbeddingModel struct {
+	Name       string
+	Dimensions int
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// EmbeddingModel returns the embedding model recorded for the database, or
+// ErrNotFound if no vectors have been stored yet.
+func (s *Store) EmbeddingModel(ctx context.Context) (*EmbeddingModel, error) {
+	return embeddingModel(ctx, s.db)
+}
+
+// embeddingModel reads the registered embedding model using q.
+func embeddingModel(ctx context.Context, q queryer) (*EmbeddingModel, error) {
+	var name, dims sql.NullString
+	err := q.QueryRowContext(ctx, `
+		SELECT
+			(SELECT value FROM metadata WHERE key = ?),
+			(SELECT value FROM metadata WHERE key = ?)
+	`, metaEmbeddingModel, metaEmbeddingDimensions).Scan(&name, &dims)
+	if err != nil {
+		return nil, fmt.Errorf("query embedding model: %w", err)
+	}
+	if !name.Valid {
+		return nil, fmt.Errorf("embedding model: %w", ErrNotFound)
+	}
+
+	n, err := strconv.Atoi(dims.String)
+	if err != nil {
+		return nil, fmt.Errorf("parse embedding dimensions %q: %w", dims.String, err)
+	}
+
+	return &EmbeddingModel{Name: name.String, Dimensions: n}, nil
+}
+
+// CheckEmbeddingModel verifies that vectors produced by the named model with
+// the given dimension are compatible with the database. It returns nil if no
+// model has been registered yet, and an error wrapping ErrModelMismatch if
+// the database was built with a different model.
+func (s *Store) CheckEmbeddingModel(ctx context.Context, name string, dims int) error {
+	current, err := s.EmbeddingModel(ctx)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return current.check(name, dims)
+}
+
+// check compares the registered model against a candidate model.
+func (m *EmbeddingModel) check(name string, dims int) error {
+	if m.Name != name || m.Dimensions != dims {
+		return fmt.Errorf("database was built with %s (%d dimensions) but got %s (%d dimensions); use --embed-model %s or run `grimoire reembed --model %s`: %w",
+			m.Name, m.Dimensions, name, dims, m.Name, name, ErrModelMismatch)
+	}
+	return nil
+}
+
+// RegisterEmbeddingModel records the embedding model used for the database's
+// vectors. The first registration sizes the vector table to dims; later calls
+// succeed only if they name the same model and dimension.
+func (s *Store) RegisterEmbeddingModel(ctx context.Context, name string, dims int) error {
+	if name == "" || dims <= 0 {
+		return fmt.Errorf("invalid embedding model %q with %d dimensions", name, dims)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := embeddingModel(ctx, tx)
+	switch {
+	case err == nil:
+		return current.check(name, dims)
+	case !errors.Is(err, ErrNotFound):
+		return err
+	}
+
+	// Vectors stored before any model was registered fix the dimension;
+	// otherwise the (empty) table is recreated at the requested size.
+	var existingDims sql.NullInt64
+	err = tx.QueryRowContext(ctx, "SELECT vec_length(embedding) FROM chunks_vec LIMIT 1").Scan(&existingDims)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		if err := createVectorTable(ctx, tx, "chunks_vec", dims); err != nil {
+			return err
+		}
+	case err != nil:
+		return fmt.Errorf("query vector dimensions: %w", err)
+	case int(existingDims.Int64) != dims:
+		return fmt.Errorf("existing vectors have %d dimensions but %s has %d: %w",
+			existingDims.Int64, name, dims, ErrModelMismatch)
+	}
+
+	err = setMetadata(ctx, tx, map[string]string{
+		metaEmbeddingModel:      name,
+		metaEmbeddingDimensions: strconv.Itoa(dims),
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// setMetadata writes metadata key/value pairs, replacing existing values.
+func setMetadata(ctx context.Context, tx *sql.Tx, values map[string]string) error {
+	for key, value := range values {
+		_, err := tx.ExecContext(ctx, "INSERT OR REPLACE INTO metadata (key, value) VALUES (?, ?)", key, value)
+		if err != nil {
+			return fmt.Errorf("record %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// checkDimensions verifies that vectors match the registered dimension.
+// Vectors are accepted as-is if no model has been registered.
+func checkDimensions(ctx context.Context, q queryer, vecs ...[]float32) error {
+	if len(vecs) == 0 {
+		return nil
+	}
+
+	current, err := embeddingModel(ctx, q)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, vec := range vecs {
+		if len(vec) != current.Dimensions {
+			return fmt.Errorf("vector has %d dimensions but database uses %s (%d dimensions): %w",
+				len(vec), current.Name, current.Dimensions, ErrModelMismatch)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jamesainslie/grimoire/internal/store"
+)
+
+func TestStore_RegisterEmbeddingModel(t *testing.T) {
+	t.Parallel()
+
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	if _, err := s.EmbeddingModel(ctx); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("EmbeddingModel() on empty store error = %v, want ErrNotFound", err)
+	}
+	if err := s.CheckEmbeddingModel(ctx, "nomic-embed-text", 768); err != nil {
+		t.Errorf("CheckEmbeddingModel() on empty store error = %v, want nil", err)
+	}
+
+	// First registration sizes the vector table
+	if err := s.RegisterEmbeddingModel(ctx, "nomic-embed-text", 768); err != nil {
+		t.Fatalf("RegisterEmbeddingModel() error = %v", err)
+	}
+	if err := s.RegisterEmbeddingModel(ctx, "nomic-embed-text", 768); err != nil {
+		t.Errorf("RegisterEmbeddingModel(same) error = %v", err)
+	}
+
+	model, err := s.EmbeddingModel(ctx)
+	if err != nil {
+		t.Fatalf("EmbeddingModel() error = %v", err)
+	}
+	if model.Name != "nomic-embed-text" || model.Dimensions != 768 {
+		t.Errorf("EmbeddingModel() = %+v, want nomic-embed-text/768", model)
+	}
+
+	lang, _ := s.CreateLanguage(ctx, "go", "Go")
+	src, _ := s.CreateSource(ctx, lang.ID, "uber-guide", "git", "https://github.com/uber-go/guide")
+
+	embedding := make([]float32, 768)
+	embedding[0] = 1.0
+	_, err = s.ReplaceDocument(ctx, &store.Document{SourceID: src.ID, Path: "style.md"}, []store.NewChunk{
+		{Level: "section", Title: "Errors", Content: strings.Repeat("Handle errors once. ", 10), Embedding: embedding},
+	})
+	if err != nil {
+		t.Fatalf("ReplaceDocument(768) error = %v", err)
+	}
+
+	results, err := s.SearchChunksVectorWithScore(ctx, embedding, store.SearchOptions{}, 5)
+	if err != nil {
+		t.Fatalf("SearchChunksVectorWithScore(768) error = %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("SearchChunksVectorWithScore(768) = %d results, want 1", len(results))
+	}
+
+	// A different model or dimension is refused with a clear error
+	tests := []struct {
+		name string
+		fn   func() error
+	}{
+		{"register other model", func() error { return s.RegisterEmbeddingModel(ctx, "snowflake-arctic-embed:l", 1024) }},
+		{"register other dimension", func() error { return s.RegisterEmbeddingModel(ctx, "nomic-embed-text", 512) }},
+		{"check other model", func() error { return s.CheckEmbeddingModel(ctx, "snowflake-arctic-embed:l", 768) }},
+		{"store wrong dimension", func() error {
+			chunk, _ := s.CreateChunk(ctx, 1, nil, "section", "Naming", "Use short names", 5)
+			return s.StoreEmbedding(ctx, chunk.ID, make([]float32, 1024))
+		}},
+		{"search wrong dimension", func() error {
+			_, err := s.SearchChunksVectorWithScore(ctx, make([]float32, 1024), store.SearchOptions{}, 5)
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.fn(); !errors.Is(err, store.ErrModelMismatch) {
+				t.Errorf("error = %v, want ErrModelMismatch", err)
+			}
+		})
+	}
+}
+
+func TestStore_RegisterEmbeddingModel_AdoptsExistingVectors(t *testing.T) {
+	t.Parallel()
+
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	lang, _ := s.CreateLanguage(ctx, "go", "Go")
+	src, _ := s.CreateSource(ctx, lang.ID, "uber-guide", "git", "https://github.com/uber-go/guide")
+	doc, _ := s.CreateDocument(ctx, src.ID, "style.md", "Style")
+	chunk, _ := s.CreateChunk(ctx, doc.ID, nil, "section", "Errors", "Handle errors once", 5)
+
+	// Vectors stored before registration keep the default table size
+	if err := s.StoreEmbedding(ctx, chunk.ID, make([]float32, 1024)); err != nil {
+		t.Fatalf("StoreEmbedding() error = %v", err)
+	}
+
+	if err := s.RegisterEmbeddingModel(ctx, "nomic-embed-text", 768); !errors.Is(err, store.ErrModelMismatch) {
+		t.Errorf("RegisterEmbeddingModel(768) error = %v, want ErrModelMismatch", err)
+	}
+	if err := s.RegisterEmbeddingModel(ctx, "snowflake-arctic-embed:l", 1024); err != nil {
+		t.Errorf("RegisterEmbeddingModel(1024) error = %v", err)
+	}
+
+	stats, err := s.GetStats(ctx)
+	if err != nil {
+		t.Fatalf("GetStats() error = %v", err)
+	}
+	if stats.Embeddings != 1 || stats.EmbeddingModel != "snowflake-arctic-embed:l" {
+		t.Errorf("stats = %+v, want 1 embedding from snowflake-arctic-embed:l", stats)
+	}
+}
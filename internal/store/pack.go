@@ -0,0 +1,113 @@
+package store
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jamesainslie/grimoire/internal/chunk"
+)
+
+// Packing budget constants.
+const (
+	// PackCandidates is the number of search results considered for packing
+	// into a token budget when the caller gives no limit.
+	PackCandidates = 50
+	// passageOverheadTokens covers the header a consumer prints above each
+	// passage besides its breadcrumbs: result number, relevance and source.
+	passageOverheadTokens = 24
+	// minTrimTokens is the smallest useful piece of a trimmed passage. Less
+	// budget than this is left unused rather than spent on a fragment.
+	minTrimTokens = 48
+)
+
+// Packing is the result of fitting passages into a token budget.
+type Packing struct {
+	Passages   []*Passage // Packed passages, best first
+	Tokens     int        // Estimated tokens used, including headers
+	Dropped    int        // Candidates left out for lack of budget
+	Duplicates int        // Candidates left out as repeats of a packed passage
+}
+
+// PackPassages greedily fits passages, best first, into an estimated
+// maxTokens. Each passage costs its content plus a header with its
+// breadcrumbs. Passages repeating the text of one already packed are
+// skipped. The first passage that does not fit is trimmed to the remaining
+// budget if a useful part of it fits, after which packing stops.
+func PackPassages(passages []*Passage, maxTokens int) *Packing {
+	packing := &Packing{}
+	seen := make(map[string]bool)
+	full := false
+
+	for _, p := range passages {
+		// Compare text ignoring case and layout, so the same advice copied
+		// between documents counts once
+		key := strings.ToLower(strings.Join(strings.Fields(p.Content()), " "))
+		if seen[key] {
+			packing.Duplicates++
+			continue
+		}
+		if full {
+			packing.Dropped++
+			continue
+		}
+
+		header := passageOverheadTokens + chunk.EstimateTokens(strings.Join(p.Breadcrumbs, " > "))
+		remaining := maxTokens - packing.Tokens - header
+		if cost := chunk.EstimateTokens(p.Content()); cost <= remaining {
+			seen[key] = true
+			packing.Passages = append(packing.Passages, p)
+			packing.Tokens += header + cost
+			continue
+		}
+
+		full = true
+		if remaining < minTrimTokens {
+			packing.Dropped++
+			continue
+		}
+		seen[key] = true
+		trimmed := p.trim(remaining)
+		packing.Passages = append(packing.Passages, trimmed)
+		packing.Tokens += header + chunk.EstimateTokens(trimmed.Content())
+	}
+	return packing
+}
+
+// trim returns a copy of p whose content fits in maxTokens, keeping whole
+// chunks from the start and cutting the first that does not fit at a word
+// boundary.
+func (p *Passage) trim(maxTokens int) *Passage {
+	trimmed := *p
+	trimmed.Chunks = nil
+	trimmed.Truncated = true
+
+	budget := maxTokens * chunk.CharsPerToken
+	for _, c := range p.Chunks {
+		// Chunks after the first are joined by a blank line
+		if len(trimmed.Chunks) > 0 {
+			budget -= 2
+		}
+		if len(c.Content) <= budget {
+			trimmed.Chunks = append(trimmed.Chunks, c)
+			budget -= len(c.Content)
+			continue
+		}
+
+		const ellipsis = "…"
+		cut := budget - len(ellipsis)
+		if cut <= 0 {
+			break
+		}
+		for cut > 0 && !utf8.RuneStart(c.Content[cut]) {
+			cut--
+		}
+		if ws := strings.LastIndexAny(c.Content[:cut], " \t\n"); ws > cut/2 {
+			cut = ws
+		}
+		partial := *c
+		partial.Content = strings.TrimSpace(c.Content[:cut]) + ellipsis
+		trimmed.Chunks = append(trimmed.Chunks, &partial)
+		break
+	}
+	return &trimmed
+}
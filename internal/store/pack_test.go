@@ -0,0 +1,91 @@
+package store_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jamesainslie/grimoire/internal/store"
+)
+
+func TestPackPassages(t *testing.T) {
+	t.Parallel()
+
+	// passage returns a passage of about tokens tokens of text
+	passage := func(word string, tokens int) *store.Passage {
+		return &store.Passage{
+			Chunks:      []*store.Chunk{{Content: strings.TrimSpace(strings.Repeat(word+" ", tokens*4/(len(word)+1)))}},
+			Breadcrumbs: []string{"Effective Go", "Errors"},
+		}
+	}
+	errorsPassage := passage("errors", 100)
+	copied := &store.Passage{
+		Chunks: []*store.Chunk{{Content: strings.ToUpper(errorsPassage.Content())}},
+	}
+
+	tests := []struct {
+		name           string
+		passages       []*store.Passage
+		maxTokens      int
+		wantPacked     int
+		wantDropped    int
+		wantDuplicates int
+		wantTruncated  bool
+	}{
+		{
+			name:       "all fit",
+			passages:   []*store.Passage{passage("alpha", 100), passage("bravo", 100)},
+			maxTokens:  1000,
+			wantPacked: 2,
+		},
+		{
+			name:          "last trimmed",
+			passages:      []*store.Passage{passage("alpha", 100), passage("bravo", 400), passage("charlie", 50)},
+			maxTokens:     400,
+			wantPacked:    2,
+			wantDropped:   1,
+			wantTruncated: true,
+		},
+		{
+			name:        "no room to trim",
+			passages:    []*store.Passage{passage("alpha", 300), passage("bravo", 300)},
+			maxTokens:   350,
+			wantPacked:  1,
+			wantDropped: 1,
+		},
+		{
+			name:           "duplicates",
+			passages:       []*store.Passage{errorsPassage, copied, passage("bravo", 100)},
+			maxTokens:      1000,
+			wantPacked:     2,
+			wantDuplicates: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			packing := store.PackPassages(tt.passages, tt.maxTokens)
+			if len(packing.Passages) != tt.wantPacked {
+				t.Errorf("packed %d passages, want %d", len(packing.Passages), tt.wantPacked)
+			}
+			if packing.Dropped != tt.wantDropped {
+				t.Errorf("Dropped = %d, want %d", packing.Dropped, tt.wantDropped)
+			}
+			if packing.Duplicates != tt.wantDuplicates {
+				t.Errorf("Duplicates = %d, want %d", packing.Duplicates, tt.wantDuplicates)
+			}
+			if packing.Tokens > tt.maxTokens {
+				t.Errorf("Tokens = %d, over the budget of %d", packing.Tokens, tt.maxTokens)
+			}
+
+			last := packing.Passages[len(packing.Passages)-1]
+			if last.Truncated != tt.wantTruncated {
+				t.Errorf("last passage Truncated = %v, want %v", last.Truncated, tt.wantTruncated)
+			}
+			if tt.wantTruncated && !strings.HasSuffix(last.Content(), "…") {
+				t.Errorf("trimmed content = %q, want it to end with an ellipsis", last.Content())
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Fusion methods for combining vector and full-text rankings.
+const (
+	// FusionRRF sums weighted reciprocal ranks. It ignores raw scores, so it
+	// needs no calibration between retrievers.
+	FusionRRF = "rrf"
+	// FusionLinear sums weighted, normalized scores: cosine similarity and
+	// BM25 relative to the best full-text match. It keeps how much better
+	// one result is than the next, which ranks alone discard.
+	FusionLinear = "linear"
+)
+
+// Defaults used when a Ranking field is left zero.
+const (
+	DefaultFusion = FusionRRF
+	// DefaultRRFK is the RRF rank constant. The usual 60 flattens scores
+	// across a handful of results; 1 keeps the top ranks well separated.
+	DefaultRRFK          = 1.0
+	DefaultCandidatePool = 50
+)
+
+// DefaultTierWeights favours official documentation over style guides,
+// books, blog posts and curated lists, in that order, without letting tier
+// outweigh a clearly better match.
+var DefaultTierWeights = map[int]float64{1: 1.0, 2: 0.95, 3: 0.9, 4: 0.85, 5: 0.8}
+
+// Ranking tunes how hybrid search orders results. Zero fields use defaults.
+type Ranking struct {
+	Fusion        string   // FusionRRF or FusionLinear
+	RRFK          float64  // Rank constant for FusionRRF; larger values flatten rank differences
+	VectorWeight  *float64 // Weight of the vector ranking; nil means 1, 0 disables it
+	TextWeight    *float64 // Weight of the full-text ranking; nil means 1, 0 disables it
+	SymbolWeight  *float64 // Weight of the code identifier ranking; nil means 1, 0 disables it
+	CandidatePool int      // Results fetched from each retriever before fusion; at least the limit
+
+	// TierWeights multiplies each result's score by the weight for its
+	// source's tier. Tiers without a weight, including unset tiers, count
+	// as 1. Nil uses DefaultTierWeights; an empty map disables the boost.
+	TierWeights map[int]float64
+}
+
+// withDefaults returns a copy of r with zero fields set to their defaults.
+func (r Ranking) withDefaults() Ranking {
+	if r.Fusion == "" {
+		r.Fusion = DefaultFusion
+	}
+	if r.RRFK == 0 {
+		r.RRFK = DefaultRRFK
+	}
+	for _, w := range []**float64{&r.VectorWeight, &r.TextWeight, &r.SymbolWeight} {
+		if *w == nil {
+			one := 1.0
+			*w = &one
+		}
+	}
+	if r.CandidatePool <= 0 {
+		r.CandidatePool = DefaultCandidatePool
+	}
+	return r
+}
+
+// Validate reports settings that cannot be used.
+func (r Ranking) Validate() error {
+	r = r.withDefaults()
+	switch {
+	case r.Fusion != FusionRRF && r.Fusion != FusionLinear:
+		return fmt.Errorf("unknown fusion method %q (must be %q or %q)", r.Fusion, FusionRRF, FusionLinear)
+	case r.RRFK < 0:
+		return fmt.Errorf("RRF k must not be negative, got %v", r.RRFK)
+	case *r.VectorWeight < 0 || *r.TextWeight < 0 || *r.SymbolWeight < 0:
+		return fmt.Errorf("retriever weights must not be negative, got vector %v, text %v, symbol %v",
+			*r.VectorWeight, *r.TextWeight, *r.SymbolWeight)
+	}
+	return nil
+}
+
+// candidate is a chunk found by one retriever.
+type candidate struct {
+	chunk    *Chunk
+	distance float64 // Vector distance, lower is better; unused for full-text results
+	score    float64 // Relevance, higher is better: cosine similarity or negated BM25
+}
+
+// retrieval is the ranked output of one retriever.
+type retrieval struct {
+	candidates []candidate
+	weight     float64
+	// bm25 marks scores as BM25, which linear fusion divides by the best
+	// score in the list; otherwise scores are cosine similarities.
+	bm25 bool
+}
+
+// fuseRRF combines rankings with weighted Reciprocal Rank Fusion:
+// score = sum(weight / (k + rank)) over the rankings a chunk appears in.
+// Scores are normalized so that a chunk ranked first by all scores 1.
+func fuseRRF(lists []retrieval, r Ranking) []*SearchResult {
+	var maxScore float64
+	scores := make(map[int64]float64)
+	chunks := make(map[int64]*Chunk)
+	for _, list := range lists {
+		maxScore += list.weight / (r.RRFK + 1)
+		for i, c := range list.candidates {
+			scores[c.chunk.ID] += list.weight / (r.RRFK + float64(i+1))
+			chunks[c.chunk.ID] = c.chunk
+		}
+	}
+
+	return fusedResults(scores, chunks, maxScore)
+}
+
+// fuseLinear combines rankings by a weighted mean of normalized scores.
+// Cosine similarity is used as is, clamped to 0-1; BM25 is divided by the
+// best BM25 in its list. A chunk missing from one ranking scores 0 there.
+func fuseLinear(lists []retrieval) []*SearchResult {
+	var maxScore float64
+	scores := make(map[int64]float64)
+	chunks := make(map[int64]*Chunk)
+	for _, list := range lists {
+		maxScore += list.weight
+
+		scale := 1.0
+		if list.bm25 {
+			var best float64
+			for _, c := range list.candidates {
+				best = max(best, c.score)
+			}
+			if best == 0 {
+				continue
+			}
+			scale = 1 / best
+		}
+		for _, c := range list.candidates {
+			scores[c.chunk.ID] += list.weight * min(max(c.score*scale, 0), 1)
+			chunks[c.chunk.ID] = c.chunk
+		}
+	}
+
+	return fusedResults(scores, chunks, maxScore)
+}
+
+// fusedResults converts fused scores to results, normalizing by maxScore to
+// a 0-1 distance where 0 is a perfect match.
+func fusedResults(scores map[int64]float64, chunks map[int64]*Chunk, maxScore float64) []*SearchResult {
+	results := make([]*SearchResult, 0, len(scores))
+	for id, score := range scores {
+		normalized := 0.0
+		if maxScore > 0 {
+			normalized = score / maxScore
+		}
+		results = append(results, &SearchResult{Chunk: chunks[id], Distance: 1.0 - normalized})
+	}
+	return results
+}
+
+// tierWeight returns the score multiplier for each tier, scaled so that the
+// largest is at most 1 and boosted scores stay within 0-1.
+func (r Ranking) tierWeight() func(tier int) float64 {
+	weights := r.TierWeights
+	if weights == nil {
+		weights = DefaultTierWeights
+	}
+
+	top := 1.0
+	for _, w := range weights {
+		top = max(top, w)
+	}
+	return func(tier int) float64 {
+		w, ok := weights[tier]
+		if !ok {
+			w = 1
+		}
+		return w / top
+	}
+}
+
+// ParseTierWeights parses tier weights written as comma-separated
+// tier=weight pairs, e.g. "1=1.0,2=0.9,3=0.8". An empty string returns nil,
+// selecting DefaultTierWeights.
+func ParseTierWeights(s string) (map[int]float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	weights := make(map[int]float64)
+	for _, pair := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("tier weight %q: want tier=weight", pair)
+		}
+		tier, err := strconv.Atoi(strings.TrimSpace(key))
+		if err != nil {
+			return nil, fmt.Errorf("tier weight %q: invalid tier: %w", pair, err)
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("tier weight %q: weight must be a non-negative number", pair)
+		}
+		weights[tier] = weight
+	}
+	return weights, nil
+}
@@ -0,0 +1,214 @@
+package store_test
+
+import (
+	"context"
+	"maps"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/jamesainslie/grimoire/internal/store"
+)
+
+func TestStore_SearchChunksHybrid_TierWeights(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	lang, _ := s.CreateLanguage(ctx, "go", "Go")
+	wiki, _ := s.CreateSource(ctx, lang.ID, "go-wiki", "git", "https://github.com/golang/wiki")
+	book, _ := s.CreateSource(ctx, lang.ID, "learn-go-with-tests", "git", "https://github.com/quii/learn-go-with-tests")
+	if err := s.SetSourceTier(ctx, wiki.ID, 1); err != nil {
+		t.Fatalf("SetSourceTier(wiki) error = %v", err)
+	}
+	if err := s.SetSourceTier(ctx, book.ID, 3); err != nil {
+		t.Fatalf("SetSourceTier(book) error = %v", err)
+	}
+
+	// The tier 3 chunk is the better match for both retrievers
+	for _, d := range []struct {
+		sourceID int64
+		content  string
+		first    float32
+	}{
+		{sourceID: wiki.ID, content: strings.Repeat("Mutexes guard shared state between goroutines. ", 3), first: 0.8},
+		{sourceID: book.ID, content: strings.Repeat("Mutexes, mutexes and more mutexes for shared state. ", 3), first: 1.0},
+	} {
+		embedding := make([]float32, 1024)
+		embedding[0] = d.first
+		embedding[1] = 0.5
+		_, err := s.ReplaceDocument(ctx, &store.Document{SourceID: d.sourceID, Path: "sync.md"}, []store.NewChunk{{
+			Level:     "section",
+			Title:     "Mutexes",
+			Content:   d.content,
+			Embedding: embedding,
+		}})
+		if err != nil {
+			t.Fatalf("ReplaceDocument() error = %v", err)
+		}
+	}
+
+	query := make([]float32, 1024)
+	query[0] = 1
+
+	tests := []struct {
+		name    string
+		weights map[int]float64
+		want    string
+	}{
+		{name: "disabled", weights: map[int]float64{}, want: "learn-go-with-tests"},
+		{name: "defaults keep the better match", weights: nil, want: "learn-go-with-tests"},
+		{name: "strong prior", weights: map[int]float64{1: 1.0, 3: 0.5}, want: "go-wiki"},
+		{name: "boost above one", weights: map[int]float64{1: 2.0}, want: "go-wiki"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Without searchable text only the vector ranking is fused,
+			// and tiers still weigh in
+			for _, text := range []string{"mutexes", ""} {
+				opts := store.SearchOptions{Ranking: store.Ranking{TierWeights: tt.weights}}
+				results, err := s.SearchChunksHybrid(ctx, query, text, opts, 5)
+				if err != nil {
+					t.Fatalf("SearchChunksHybrid(%q) error = %v", text, err)
+				}
+				if len(results) != 2 {
+					t.Fatalf("SearchChunksHybrid(%q) = %d results, want 2", text, len(results))
+				}
+				if got := results[0].Source.Name; got != tt.want {
+					t.Errorf("SearchChunksHybrid(%q) first source = %s, want %s", text, got, tt.want)
+				}
+				for _, r := range results {
+					if r.Distance < 0 || r.Distance > 1 {
+						t.Errorf("SearchChunksHybrid(%q) distance = %v, want within 0-1", text, r.Distance)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestStore_SearchChunksHybrid_Fusion(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	lang, _ := s.CreateLanguage(ctx, "go", "Go")
+	src, _ := s.CreateSource(ctx, lang.ID, "go-wiki", "git", "https://github.com/golang/wiki")
+
+	// "semantic" is nearest to the query but never mentions the keyword,
+	// "keyword" is the best text match but semantically unrelated, and
+	// "both" is second best for each retriever.
+	for _, d := range []struct {
+		title   string
+		content string
+		vec     map[int]float32
+	}{
+		{title: "semantic", content: strings.Repeat("Share memory by communicating over typed pipes. ", 3), vec: map[int]float32{0: 1}},
+		{title: "keyword", content: strings.Repeat("Channels, channels, channels everywhere you look. ", 3), vec: map[int]float32{2: 1}},
+		{title: "both", content: strings.Repeat("Channels let goroutines communicate safely today. ", 3), vec: map[int]float32{0: 0.9, 1: float32(math.Sqrt(1 - 0.81))}},
+	} {
+		embedding := make([]float32, 1024)
+		for i, v := range d.vec {
+			embedding[i] = v
+		}
+		_, err := s.ReplaceDocument(ctx, &store.Document{SourceID: src.ID, Path: d.title + ".md"}, []store.NewChunk{{
+			Level:     "section",
+			Title:     d.title,
+			Content:   d.content,
+			Embedding: embedding,
+		}})
+		if err != nil {
+			t.Fatalf("ReplaceDocument(%s) error = %v", d.title, err)
+		}
+	}
+
+	query := make([]float32, 1024)
+	query[0] = 1
+
+	tests := []struct {
+		name    string
+		ranking store.Ranking
+		want    string
+	}{
+		{name: "linear", ranking: store.Ranking{Fusion: store.FusionLinear}, want: "both"},
+		{name: "linear vector weighted", ranking: store.Ranking{Fusion: store.FusionLinear, TextWeight: weight(0.001)}, want: "semantic"},
+		{name: "linear text weighted", ranking: store.Ranking{Fusion: store.FusionLinear, VectorWeight: weight(0.001)}, want: "keyword"},
+		{name: "rrf vector weighted", ranking: store.Ranking{Fusion: store.FusionRRF, TextWeight: weight(0.001)}, want: "semantic"},
+		{name: "rrf text weighted", ranking: store.Ranking{Fusion: store.FusionRRF, VectorWeight: weight(0.001)}, want: "keyword"},
+		{name: "text disabled", ranking: store.Ranking{Fusion: store.FusionRRF, TextWeight: weight(0)}, want: "semantic"},
+		{name: "vector disabled", ranking: store.Ranking{Fusion: store.FusionLinear, VectorWeight: weight(0)}, want: "keyword"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := s.SearchChunksHybrid(ctx, query, "channels", store.SearchOptions{Ranking: tt.ranking}, 1)
+			if err != nil {
+				t.Fatalf("SearchChunksHybrid() error = %v", err)
+			}
+			if len(results) != 1 {
+				t.Fatalf("SearchChunksHybrid() = %d results, want 1", len(results))
+			}
+			if got := results[0].Chunk.Title; got != tt.want {
+				t.Errorf("SearchChunksHybrid() top result = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("candidate pool", func(t *testing.T) {
+		// With one candidate per retriever, fusion never sees the chunk
+		// both retrievers rank second.
+		ranking := store.Ranking{Fusion: store.FusionLinear, CandidatePool: 1}
+		results, err := s.SearchChunksHybrid(ctx, query, "channels", store.SearchOptions{Ranking: ranking}, 1)
+		if err != nil {
+			t.Fatalf("SearchChunksHybrid() error = %v", err)
+		}
+		if len(results) != 1 || results[0].Chunk.Title == "both" {
+			t.Errorf("SearchChunksHybrid(pool 1) = %d results, want 1 other than \"both\"", len(results))
+		}
+	})
+
+	t.Run("unknown fusion", func(t *testing.T) {
+		ranking := store.Ranking{Fusion: "borda"}
+		if _, err := s.SearchChunksHybrid(ctx, query, "channels", store.SearchOptions{Ranking: ranking}, 1); err == nil {
+			t.Error("SearchChunksHybrid(unknown fusion) error = nil, want error")
+		}
+	})
+}
+
+func TestParseTierWeights(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input   string
+		want    map[int]float64
+		wantErr bool
+	}{
+		{input: "", want: nil},
+		{input: "1=1.0, 2=0.9,3=0.75", want: map[int]float64{1: 1.0, 2: 0.9, 3: 0.75}},
+		{input: "1", wantErr: true},
+		{input: "one=1", wantErr: true},
+		{input: "1=-0.5", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := store.ParseTierWeights(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTierWeights(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("ParseTierWeights(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// weight returns a pointer to a retriever weight.
+func weight(w float64) *float64 {
+	return &w
+}
@@ -0,0 +1,224 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Metadata keys recording an in-progress re-embed.
+const (
+	metaReembedModel      = "reembed_model"
+	metaReembedDimensions = "reembed_dimensions"
+)
+
+// Re-embedding writes new vectors into a staging table, chunks_vec_next, so
+// searches keep using the old vectors until every chunk has been converted.
+// Progress lives in the staging table itself, which makes an interrupted
+// re-embed resumable.
+
+// ReembedTarget returns the model of an in-progress re-embed, or ErrNotFound
+// if none is in progress.
+func (s *Store) ReembedTarget(ctx context.Context) (*EmbeddingModel, error) {
+	var name, dims sql.NullString
+	err := s.db.QueryRowContext(ctx, `
+		SELECT
+			(SELECT value FROM metadata WHERE key = ?),
+			(SELECT value FROM metadata WHERE key = ?)
+	`, metaReembedModel, metaReembedDimensions).Scan(&name, &dims)
+	if err != nil {
+		return nil, fmt.Errorf("query re-embed target: %w", err)
+	}
+	if !name.Valid {
+		return nil, fmt.Errorf("re-embed target: %w", ErrNotFound)
+	}
+
+	n, err := strconv.Atoi(dims.String)
+	if err != nil {
+		return nil, fmt.Errorf("parse re-embed dimensions %q: %w", dims.String, err)
+	}
+
+	return &EmbeddingModel{Name: name.String, Dimensions: n}, nil
+}
+
+// BeginReembed starts re-embedding the database with a new model, discarding
+// any in-progress re-embed to a different model. If a re-embed to the same
+// model and dimension is already in progress it is left intact so it can be
+// resumed.
+func (s *Store) BeginReembed(ctx context.Context, name string, dims int) error {
+	if name == "" || dims <= 0 {
+		return fmt.Errorf("invalid embedding model %q with %d dimensions", name, dims)
+	}
+
+	target, err := s.ReembedTarget(ctx)
+	if err == nil && target.Name == name && target.Dimensions == dims {
+		return nil
+	}
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := createVectorTable(ctx, tx, "chunks_vec_next", dims); err != nil {
+		return err
+	}
+
+	err = setMetadata(ctx, tx, map[string]string{
+		metaReembedModel:      name,
+		metaReembedDimensions: strconv.Itoa(dims),
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ReembedProgress reports how many embedded chunks have been converted to the
+// new model and the highest converted chunk ID, from which work resumes.
+// Staged vectors for chunks deleted since they were converted do not count
+// towards done, so done never exceeds total.
+func (s *Store) ReembedProgress(ctx context.Context) (done, total, lastID int64, err error) {
+	err = s.db.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM chunks_vec_next WHERE chunk_id IN (SELECT chunk_id FROM chunks_vec)),
+			(SELECT COALESCE(MAX(chunk_id), 0) FROM chunks_vec_next)
+	`).Scan(&done, &lastID)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("query re-embed progress: %w", err)
+	}
+
+	err = s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM chunks_vec").Scan(&total)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("count embeddings: %w", err)
+	}
+
+	return done, total, lastID, nil
+}
+
+// NextReembedBatch returns up to limit chunks with IDs greater than afterID
+// that have an embedding under the current model, ordered by ID.
+func (s *Store) NextReembedBatch(ctx context.Context, afterID int64, limit int) ([]*Chunk, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+chunkColumns+`
+		FROM chunks c
+		WHERE c.id > ? AND c.id IN (SELECT chunk_id FROM chunks_vec)
+		ORDER BY c.id
+		LIMIT ?
+	`, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query chunks: %w", err)
+	}
+	defer rows.Close()
+
+	var chunks []*Chunk
+	for rows.Next() {
+		chunk, err := scanChunk(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan chunk: %w", err)
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate chunks: %w", err)
+	}
+
+	return chunks, nil
+}
+
+// StoreReembedBatch writes new-model embeddings for the given chunks into the
+// staging table in a single transaction.
+func (s *Store) StoreReembedBatch(ctx context.Context, chunkIDs []int64, embeddings [][]float32) error {
+	if len(chunkIDs) != len(embeddings) {
+		return fmt.Errorf("got %d embeddings for %d chunks", len(embeddings), len(chunkIDs))
+	}
+
+	target, err := s.ReembedTarget(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for i, id := range chunkIDs {
+		if len(embeddings[i]) != target.Dimensions {
+			return fmt.Errorf("vector has %d dimensions but re-embed target %s has %d: %w",
+				len(embeddings[i]), target.Name, target.Dimensions, ErrModelMismatch)
+		}
+		err := insertVector(ctx, tx, "chunks_vec_next", id, embeddings[i])
+		if errors.Is(err, ErrNotFound) {
+			continue // Chunk deleted since the batch was read
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// FinishReembed atomically replaces the vector table with the re-embedded
+// vectors and records the new model. It fails without changing anything if
+// any embedded chunk has not been converted yet.
+func (s *Store) FinishReembed(ctx context.Context) error {
+	target, err := s.ReembedTarget(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var missing int64
+	err = tx.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM chunks_vec WHERE chunk_id NOT IN (SELECT chunk_id FROM chunks_vec_next)",
+	).Scan(&missing)
+	if err != nil {
+		return fmt.Errorf("count remaining chunks: %w", err)
+	}
+	if missing > 0 {
+		return fmt.Errorf("re-embed incomplete: %d chunks remaining", missing)
+	}
+
+	// sqlite-vec tables cannot be renamed, so copy the staged vectors into a
+	// fresh table instead. Vectors for chunks deleted mid-run are dropped, and
+	// metadata is re-read in case a source changed while the run was paused.
+	if err := createVectorTable(ctx, tx, "chunks_vec", target.Dimensions); err != nil {
+		return err
+	}
+	if err := copyVectors(ctx, tx, "chunks_vec_next"); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DROP TABLE chunks_vec_next"); err != nil {
+		return fmt.Errorf("drop staging table: %w", err)
+	}
+
+	err = setMetadata(ctx, tx, map[string]string{
+		metaEmbeddingModel:      target.Name,
+		metaEmbeddingDimensions: strconv.Itoa(target.Dimensions),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM metadata WHERE key IN (?, ?)", metaReembedModel, metaReembedDimensions)
+	if err != nil {
+		return fmt.Errorf("clear re-embed target: %w", err)
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,140 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jamesainslie/grimoire/internal/store"
+)
+
+func TestStore_Reembed(t *testing.T) {
+	t.Parallel()
+
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	if err := s.RegisterEmbeddingModel(ctx, "snowflake-arctic-embed:l", 1024); err != nil {
+		t.Fatalf("RegisterEmbeddingModel() error = %v", err)
+	}
+	lang, _ := s.CreateLanguage(ctx, "go", "Go")
+	src, _ := s.CreateSource(ctx, lang.ID, "uber-guide", "git", "https://github.com/uber-go/guide")
+	seedDocument(t, s, src.ID, "style.md")
+	seedDocument(t, s, src.ID, "README.md")
+
+	if err := s.BeginReembed(ctx, "nomic-embed-text", 768); err != nil {
+		t.Fatalf("BeginReembed() error = %v", err)
+	}
+
+	// Convert the first chunk, then simulate an interruption
+	batch, err := s.NextReembedBatch(ctx, 0, 1)
+	if err != nil {
+		t.Fatalf("NextReembedBatch() error = %v", err)
+	}
+	if len(batch) != 1 {
+		t.Fatalf("NextReembedBatch() = %d chunks, want 1", len(batch))
+	}
+	if err := s.StoreReembedBatch(ctx, []int64{batch[0].ID}, [][]float32{make([]float32, 768)}); err != nil {
+		t.Fatalf("StoreReembedBatch() error = %v", err)
+	}
+
+	if err := s.FinishReembed(ctx); err == nil {
+		t.Error("FinishReembed() with chunks remaining succeeded, want error")
+	}
+
+	// Old vectors keep serving queries until the swap
+	if _, err := s.SearchChunksVectorWithScore(ctx, make([]float32, 1024), store.SearchOptions{}, 5); err != nil {
+		t.Errorf("SearchChunksVectorWithScore(old model) during re-embed error = %v", err)
+	}
+
+	// Beginning again with the same model resumes
+	if err := s.BeginReembed(ctx, "nomic-embed-text", 768); err != nil {
+		t.Fatalf("BeginReembed(resume) error = %v", err)
+	}
+	done, total, lastID, err := s.ReembedProgress(ctx)
+	if err != nil {
+		t.Fatalf("ReembedProgress() error = %v", err)
+	}
+	if done != 1 || total != 2 || lastID != batch[0].ID {
+		t.Errorf("ReembedProgress() = %d/%d after %d, want 1/2 after %d", done, total, lastID, batch[0].ID)
+	}
+
+	rest, err := s.NextReembedBatch(ctx, lastID, 10)
+	if err != nil {
+		t.Fatalf("NextReembedBatch(resume) error = %v", err)
+	}
+	if len(rest) != 1 {
+		t.Fatalf("NextReembedBatch(resume) = %d chunks, want 1", len(rest))
+	}
+	embedding := make([]float32, 768)
+	embedding[0] = 1.0
+	if err := s.StoreReembedBatch(ctx, []int64{rest[0].ID}, [][]float32{embedding}); err != nil {
+		t.Fatalf("StoreReembedBatch(resume) error = %v", err)
+	}
+
+	if err := s.FinishReembed(ctx); err != nil {
+		t.Fatalf("FinishReembed() error = %v", err)
+	}
+
+	model, err := s.EmbeddingModel(ctx)
+	if err != nil {
+		t.Fatalf("EmbeddingModel() error = %v", err)
+	}
+	if model.Name != "nomic-embed-text" || model.Dimensions != 768 {
+		t.Errorf("EmbeddingModel() = %+v, want nomic-embed-text/768", model)
+	}
+	if _, err := s.ReembedTarget(ctx); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("ReembedTarget() after finish error = %v, want ErrNotFound", err)
+	}
+
+	results, err := s.SearchChunksVectorWithScore(ctx, embedding, store.SearchOptions{}, 5)
+	if err != nil {
+		t.Fatalf("SearchChunksVectorWithScore(new model) error = %v", err)
+	}
+	if len(results) != 2 || results[0].Chunk.ID != rest[0].ID {
+		t.Errorf("SearchChunksVectorWithScore(new model) = %d results, want 2 led by chunk %d", len(results), rest[0].ID)
+	}
+	assertCounts(t, s, 2, 2, 2)
+}
+
+func TestStore_ReembedProgress_DeletedChunk(t *testing.T) {
+	t.Parallel()
+
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	if err := s.RegisterEmbeddingModel(ctx, "snowflake-arctic-embed:l", 1024); err != nil {
+		t.Fatalf("RegisterEmbeddingModel() error = %v", err)
+	}
+	lang, _ := s.CreateLanguage(ctx, "go", "Go")
+	src, _ := s.CreateSource(ctx, lang.ID, "uber-guide", "git", "https://github.com/uber-go/guide")
+	style := seedDocument(t, s, src.ID, "style.md")
+	seedDocument(t, s, src.ID, "README.md")
+
+	if err := s.BeginReembed(ctx, "nomic-embed-text", 768); err != nil {
+		t.Fatalf("BeginReembed() error = %v", err)
+	}
+	batch, err := s.NextReembedBatch(ctx, 0, 1)
+	if err != nil || len(batch) != 1 {
+		t.Fatalf("NextReembedBatch() = %d chunks, %v; want 1 chunk", len(batch), err)
+	}
+	if batch[0].DocumentID != style.ID {
+		t.Fatalf("NextReembedBatch() chunk from document %d, want %d", batch[0].DocumentID, style.ID)
+	}
+	if err := s.StoreReembedBatch(ctx, []int64{batch[0].ID}, [][]float32{make([]float32, 768)}); err != nil {
+		t.Fatalf("StoreReembedBatch() error = %v", err)
+	}
+
+	// Deleting a converted chunk mid-run must not count towards progress
+	if err := s.DeleteDocument(ctx, style.ID); err != nil {
+		t.Fatalf("DeleteDocument() error = %v", err)
+	}
+
+	done, total, lastID, err := s.ReembedProgress(ctx)
+	if err != nil {
+		t.Fatalf("ReembedProgress() error = %v", err)
+	}
+	if done != 0 || total != 1 || lastID != batch[0].ID {
+		t.Errorf("ReembedProgress() = %d/%d after %d, want 0/1 after %d", done, total, lastID, batch[0].ID)
+	}
+}
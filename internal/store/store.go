@@ -9,6 +9,8 @@ import (
 	"fmt"
 	"math"
 	"sort"
+	"strings"
+	"time"
 
 	sqlite_vec "github.com/asg017/sqlite-vec-go-bindings/cgo"
 	_ "github.com/mattn/go-sqlite3"
@@ -37,14 +39,19 @@ type Source struct {
 	Name       string
 	Type       string // "git" or "web"
 	URL        string
+	Tier       int    // Priority tier (1=official, 2=industry, etc.); 0 if unset
+	Revision   string // Commit the source was last ingested at; empty if unknown
 }
 
 // Document represents a single document (file or page) from a source.
 type Document struct {
-	ID       int64
-	SourceID int64
-	Path     string
-	Title    string
+	ID          int64
+	SourceID    int64
+	Path        string
+	Title       string
+	ContentHash string    // Hash of the raw content the chunks were built from
+	FetchedAt   time.Time // When the indexed content was fetched
+	Headings    []Heading // Headings in document order; written to the topic index by ReplaceDocument, not read back
 }
 
 // Chunk represents a piece of content from a document.
@@ -56,12 +63,35 @@ type Chunk struct {
 	Title         string
 	Content       string
 	TokenCount    int
+	Breadcrumbs   []string // Headings from the document title down to the chunk's section; empty if not recorded
+	Anchor        string   // Anchor of the chunk's section heading; empty for summaries and if not recorded
+}
+
+// chunkColumns selects a chunk c in the order scanChunk expects.
+const chunkColumns = "c.id, c.document_id, c.parent_chunk_id, c.level, c.title, c.content, c.token_count, c.breadcrumbs, c.anchor"
+
+// scanChunk scans a row that starts with chunkColumns into a Chunk, and any
+// further columns into extra.
+func scanChunk(rows *sql.Rows, extra ...any) (*Chunk, error) {
+	var chunk Chunk
+	var breadcrumbs string
+	dest := append([]any{&chunk.ID, &chunk.DocumentID, &chunk.ParentChunkID, &chunk.Level, &chunk.Title, &chunk.Content, &chunk.TokenCount, &breadcrumbs, &chunk.Anchor}, extra...)
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	if breadcrumbs != "" {
+		// Headings never span lines, so a newline separates them
+		chunk.Breadcrumbs = strings.Split(breadcrumbs, "\n")
+	}
+	return &chunk, nil
 }
 
 // SearchResult represents a chunk with its similarity score.
 type SearchResult struct {
 	Chunk    *Chunk
-	Distance float64 // Lower distance = more similar
+	Source   *Source   // Source the chunk's document belongs to
+	Citation *Citation // Where the chunk came from, for citing it
+	Distance float64   // Lower distance = more similar
 }
 
 // isQualityChunk returns true if a chunk has enough content to be useful for retrieval.
@@ -86,107 +116,58 @@ func init() {
 	sqlite_vec.Auto()
 }
 
-// New creates a new Store with the given database path.
-// Use ":memory:" for an in-memory database.
+// New creates a new Store with the given database path and applies any
+// pending schema migrations. Use ":memory:" for an in-memory database.
+// It returns ErrSchemaTooNew if the database was created by a newer binary.
 func New(path string) (*Store, error) {
-	db, err := sql.Open("sqlite3", path)
+	s, err := Open(path)
 	if err != nil {
-		return nil, fmt.Errorf("open database: %w", err)
+		return nil, err
 	}
 
-	s := &Store{db: db}
-
-	if err := s.init(); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("initialize schema: %w", err)
+	if _, err := s.Migrate(context.Background()); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("migrate schema: %w", err)
 	}
 
 	return s, nil
 }
 
-// Close closes the database connection.
-func (s *Store) Close() error {
-	return s.db.Close()
-}
+// Open opens the database at path without applying migrations.
+// Most callers want New; Open exists so tools can inspect pending
+// migrations before deciding to apply them.
+func Open(path string) (*Store, error) {
+	dsn := path
+	if path != ":memory:" {
+		// Transactions take the write lock when they begin, as BEGIN
+		// IMMEDIATE. A deferred transaction that reads and then writes
+		// fails at once if another process is writing, whereas this waits
+		// on the busy timeout; it keeps two processes from racing to
+		// migrate a new database.
+		sep := "?"
+		if strings.Contains(path, "?") {
+			sep = "&"
+		}
+		dsn += sep + "_txlock=immediate"
+	}
 
-// init creates the database schema.
-func (s *Store) init() error {
-	schema := `
-		CREATE TABLE IF NOT EXISTS languages (
-			id INTEGER PRIMARY KEY,
-			name TEXT NOT NULL UNIQUE,
-			display_name TEXT NOT NULL
-		);
-
-		CREATE TABLE IF NOT EXISTS sources (
-			id INTEGER PRIMARY KEY,
-			language_id INTEGER NOT NULL REFERENCES languages(id),
-			name TEXT NOT NULL,
-			type TEXT NOT NULL,
-			url TEXT NOT NULL,
-			UNIQUE(language_id, name)
-		);
-
-		CREATE TABLE IF NOT EXISTS documents (
-			id INTEGER PRIMARY KEY,
-			source_id INTEGER NOT NULL REFERENCES sources(id),
-			path TEXT NOT NULL,
-			title TEXT,
-			UNIQUE(source_id, path)
-		);
-
-		CREATE TABLE IF NOT EXISTS chunks (
-			id INTEGER PRIMARY KEY,
-			document_id INTEGER NOT NULL REFERENCES documents(id),
-			parent_chunk_id INTEGER REFERENCES chunks(id),
-			level TEXT NOT NULL,
-			title TEXT,
-			content TEXT NOT NULL,
-			token_count INTEGER
-		);
-
-		CREATE VIRTUAL TABLE IF NOT EXISTS chunks_fts USING fts5(
-			title,
-			content,
-			content='chunks',
-			content_rowid='id'
-		);
-
-		CREATE TRIGGER IF NOT EXISTS chunks_ai AFTER INSERT ON chunks BEGIN
-			INSERT INTO chunks_fts(rowid, title, content)
-			VALUES (new.id, new.title, new.content);
-		END;
-
-		CREATE TRIGGER IF NOT EXISTS chunks_ad AFTER DELETE ON chunks BEGIN
-			INSERT INTO chunks_fts(chunks_fts, rowid, title, content)
-			VALUES ('delete', old.id, old.title, old.content);
-		END;
-
-		CREATE TRIGGER IF NOT EXISTS chunks_au AFTER UPDATE ON chunks BEGIN
-			INSERT INTO chunks_fts(chunks_fts, rowid, title, content)
-			VALUES ('delete', old.id, old.title, old.content);
-			INSERT INTO chunks_fts(rowid, title, content)
-			VALUES (new.id, new.title, new.content);
-		END;
-	`
-
-	_, err := s.db.Exec(schema)
-	if err != nil {
-		return fmt.Errorf("create schema: %w", err)
-	}
-
-	// Create vector table (requires separate statement)
-	_, err = s.db.Exec(`
-		CREATE VIRTUAL TABLE IF NOT EXISTS chunks_vec USING vec0(
-			chunk_id INTEGER PRIMARY KEY,
-			embedding FLOAT[1024]
-		)
-	`)
+	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
-		return fmt.Errorf("create vector table: %w", err)
+		return nil, fmt.Errorf("open database: %w", err)
 	}
 
-	return nil
+	// Each connection to ":memory:" gets its own private database, so the
+	// pool must never grow beyond the connection holding the schema.
+	if path == ":memory:" {
+		db.SetMaxOpenConns(1)
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close closes the database connection.
+func (s *Store) Close() error {
+	return s.db.Close()
 }
 
 // CreateLanguage creates a new language in the store.
@@ -287,11 +268,11 @@ func (s *Store) ListSources(ctx context.Context, languageID int64) ([]*Source, e
 
 	if languageID == 0 {
 		rows, err = s.db.QueryContext(ctx,
-			"SELECT id, language_id, name, type, url FROM sources ORDER BY name",
+			"SELECT id, language_id, name, type, url, tier, revision FROM sources ORDER BY name",
 		)
 	} else {
 		rows, err = s.db.QueryContext(ctx,
-			"SELECT id, language_id, name, type, url FROM sources WHERE language_id = ? ORDER BY name",
+			"SELECT id, language_id, name, type, url, tier, revision FROM sources WHERE language_id = ? ORDER BY name",
 			languageID,
 		)
 	}
@@ -303,7 +284,7 @@ func (s *Store) ListSources(ctx context.Context, languageID int64) ([]*Source, e
 	var sources []*Source
 	for rows.Next() {
 		var src Source
-		if err := rows.Scan(&src.ID, &src.LanguageID, &src.Name, &src.Type, &src.URL); err != nil {
+		if err := rows.Scan(&src.ID, &src.LanguageID, &src.Name, &src.Type, &src.URL, &src.Tier, &src.Revision); err != nil {
 			return nil, fmt.Errorf("scan source: %w", err)
 		}
 		sources = append(sources, &src)
@@ -316,16 +297,85 @@ func (s *Store) ListSources(ctx context.Context, languageID int64) ([]*Source, e
 	return sources, nil
 }
 
+// GetSource retrieves a source by language ID and name.
+func (s *Store) GetSource(ctx context.Context, languageID int64, name string) (*Source, error) {
+	var src Source
+	err := s.db.QueryRowContext(ctx,
+		"SELECT id, language_id, name, type, url, tier, revision FROM sources WHERE language_id = ? AND name = ?",
+		languageID, name,
+	).Scan(&src.ID, &src.LanguageID, &src.Name, &src.Type, &src.URL, &src.Tier, &src.Revision)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("source %q: %w", name, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query source: %w", err)
+	}
+
+	return &src, nil
+}
+
+// SetSourceRevision records the commit a source's documents were ingested
+// at, which result citations link to.
+func (s *Store) SetSourceRevision(ctx context.Context, sourceID int64, revision string) error {
+	result, err := s.db.ExecContext(ctx, "UPDATE sources SET revision = ? WHERE id = ?", revision, sourceID)
+	if err != nil {
+		return fmt.Errorf("update source revision: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("source %d: %w", sourceID, ErrNotFound)
+	}
+	return nil
+}
+
+// SetSourceTier sets a source's priority tier, which hybrid search uses as a
+// ranking prior.
+func (s *Store) SetSourceTier(ctx context.Context, sourceID int64, tier int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "UPDATE sources SET tier = ? WHERE id = ?", tier, sourceID)
+	if err != nil {
+		return fmt.Errorf("update source tier: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("update source tier: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("source %d: %w", sourceID, ErrNotFound)
+	}
+
+	// Keep the copy used for filtering inside vector search in step
+	if _, err := tx.ExecContext(ctx, "UPDATE chunks_vec SET tier = ? WHERE source_id = ?", tier, sourceID); err != nil {
+		return fmt.Errorf("update vector tier: %w", err)
+	}
+
+	return tx.Commit()
+}
+
 // GetDocumentByPath returns a document by its source ID and path.
 func (s *Store) GetDocumentByPath(ctx context.Context, sourceID int64, path string) (*Document, error) {
 	var doc Document
+	var contentHash sql.NullString
+	var fetchedAt sql.NullTime
 	err := s.db.QueryRowContext(ctx,
-		"SELECT id, source_id, path, title FROM documents WHERE source_id = ? AND path = ?",
+		"SELECT id, source_id, path, COALESCE(title, ''), content_hash, fetched_at FROM documents WHERE source_id = ? AND path = ?",
 		sourceID, path,
-	).Scan(&doc.ID, &doc.SourceID, &doc.Path, &doc.Title)
+	).Scan(&doc.ID, &doc.SourceID, &doc.Path, &doc.Title, &contentHash, &fetchedAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("document %q: %w", path, ErrNotFound)
+	}
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("query document: %w", err)
 	}
+
+	doc.ContentHash = contentHash.String
+	doc.FetchedAt = fetchedAt.Time
 	return &doc, nil
 }
 
@@ -366,6 +416,9 @@ func (s *Store) CreateChunk(ctx context.Context, documentID int64, parentChunkID
 	if err != nil {
 		return nil, fmt.Errorf("get last insert id: %w", err)
 	}
+	if err := insertSymbols(ctx, s.db, id, title, content); err != nil {
+		return nil, err
+	}
 
 	return &Chunk{
 		ID:            id,
@@ -378,13 +431,179 @@ func (s *Store) CreateChunk(ctx context.Context, documentID int64, parentChunkID
 	}, nil
 }
 
-// SearchChunksFTS searches chunks using full-text search.
-// Pass languageID=0 to search all languages.
+// NewChunk is a chunk to be written by ReplaceDocument.
+type NewChunk struct {
+	ParentIndex *int // Index of the parent within the same slice, if any
+	Level       string
+	Title       string
+	Content     string
+	TokenCount  int
+	Breadcrumbs []string  // Headings from the document title down to the chunk's section
+	Anchor      string    // Anchor of the chunk's section heading
+	Embedding   []float32 // Nil if the chunk has no embedding
+}
+
+// ReplaceDocument creates or updates a document and replaces all of its chunks,
+// FTS entries, embeddings and topics in a single transaction. If any write fails the
+// previously indexed version of the document is left untouched.
+func (s *Store) ReplaceDocument(ctx context.Context, doc *Document, chunks []NewChunk) (*Document, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var embeddings [][]float32
+	for _, c := range chunks {
+		if c.Embedding != nil {
+			embeddings = append(embeddings, c.Embedding)
+		}
+	}
+	if err := checkDimensions(ctx, tx, embeddings...); err != nil {
+		return nil, err
+	}
+
+	fetchedAt := time.Now().UTC()
+
+	var docID int64
+	err = tx.QueryRowContext(ctx,
+		"SELECT id FROM documents WHERE source_id = ? AND path = ?",
+		doc.SourceID, doc.Path,
+	).Scan(&docID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		result, err := tx.ExecContext(ctx,
+			"INSERT INTO documents (source_id, path, title, content_hash, fetched_at) VALUES (?, ?, ?, ?, ?)",
+			doc.SourceID, doc.Path, doc.Title, doc.ContentHash, fetchedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("insert document: %w", err)
+		}
+		docID, err = result.LastInsertId()
+		if err != nil {
+			return nil, fmt.Errorf("get last insert id: %w", err)
+		}
+	case err != nil:
+		return nil, fmt.Errorf("query document: %w", err)
+	default:
+		_, err = tx.ExecContext(ctx,
+			"UPDATE documents SET title = ?, content_hash = ?, fetched_at = ? WHERE id = ?",
+			doc.Title, doc.ContentHash, fetchedAt, docID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("update document: %w", err)
+		}
+		if err := deleteDocumentChunks(ctx, tx, docID); err != nil {
+			return nil, err
+		}
+	}
+
+	chunkIDs := make([]int64, len(chunks))
+	for i, c := range chunks {
+		var parentID *int64
+		if c.ParentIndex != nil && *c.ParentIndex < i {
+			parentID = &chunkIDs[*c.ParentIndex]
+		}
+
+		result, err := tx.ExecContext(ctx,
+			"INSERT INTO chunks (document_id, parent_chunk_id, level, title, content, token_count, breadcrumbs, anchor) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			docID, parentID, c.Level, c.Title, c.Content, c.TokenCount, strings.Join(c.Breadcrumbs, "\n"), c.Anchor,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("insert chunk: %w", err)
+		}
+		chunkIDs[i], err = result.LastInsertId()
+		if err != nil {
+			return nil, fmt.Errorf("get last insert id: %w", err)
+		}
+		if err := insertSymbols(ctx, tx, chunkIDs[i], c.Title, c.Content); err != nil {
+			return nil, err
+		}
+
+		if c.Embedding == nil {
+			continue
+		}
+		if err := insertVector(ctx, tx, "chunks_vec", chunkIDs[i], c.Embedding); err != nil {
+			return nil, err
+		}
+	}
+
+	title := doc.Title
+	if title == "" {
+		title = doc.Path
+	}
+	if err := replaceTopics(ctx, tx, docID, title, doc.Headings); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+
+	return &Document{
+		ID:          docID,
+		SourceID:    doc.SourceID,
+		Path:        doc.Path,
+		Title:       doc.Title,
+		ContentHash: doc.ContentHash,
+		FetchedAt:   fetchedAt,
+	}, nil
+}
+
+// SearchChunksFTS searches chunks using full-text search, restricted by opts.
+// The query is natural language unless opts.AdvancedQuery is set; see FTSQuery.
 // Results are filtered to exclude low-quality chunks (empty, too short, or title-only).
-func (s *Store) SearchChunksFTS(ctx context.Context, query string, languageID int64, limit int) ([]*Chunk, error) {
-	var rows *sql.Rows
-	var err error
+func (s *Store) SearchChunksFTS(ctx context.Context, query string, opts SearchOptions, limit int) ([]*Chunk, error) {
+	match := ftsMatch(query, opts)
+	if match == "" {
+		return nil, nil
+	}
+	filter, err := s.searchFilter(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
 
+	candidates, err := s.ftsCandidates(ctx, "chunks_fts", match, filter, limit)
+	if err != nil {
+		return nil, err
+	}
+	return candidateChunks(candidates), nil
+}
+
+// SearchChunksSymbols searches the code identifier index, restricted by opts.
+// Identifiers such as "sync.WaitGroup" match as a whole, and parts such as
+// "WaitGroup" or "wait group" match their camelCase and dotted components.
+// The query is interpreted as for SearchChunksFTS.
+func (s *Store) SearchChunksSymbols(ctx context.Context, query string, opts SearchOptions, limit int) ([]*Chunk, error) {
+	match := ftsMatch(query, opts)
+	if match == "" {
+		return nil, nil
+	}
+	filter, err := s.searchFilter(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := s.ftsCandidates(ctx, "chunks_symbols", match, filter, limit)
+	if err != nil {
+		return nil, err
+	}
+	return candidateChunks(candidates), nil
+}
+
+// candidateChunks returns the chunks of candidates.
+func candidateChunks(candidates []candidate) []*Chunk {
+	chunks := make([]*Chunk, len(candidates))
+	for i, c := range candidates {
+		chunks[i] = c.chunk
+	}
+	return chunks
+}
+
+// ftsCandidates returns up to limit quality chunks matching an FTS5 match
+// expression in the given full-text table, best first, scored by negated
+// BM25. table must be a trusted constant.
+func (s *Store) ftsCandidates(ctx context.Context, table, match string, filter *searchFilter, limit int) ([]candidate, error) {
 	// Request more results to account for quality filtering.
 	// For queries that match section headers exactly (e.g., "error handling"),
 	// up to 90% of top results may be title-only chunks that get filtered out.
@@ -394,42 +613,41 @@ func (s *Store) SearchChunksFTS(ctx context.Context, query string, languageID in
 		fetchLimit = 50
 	}
 
-	if languageID == 0 {
-		rows, err = s.db.QueryContext(ctx, `
-			SELECT c.id, c.document_id, c.parent_chunk_id, c.level, c.title, c.content, c.token_count
-			FROM chunks c
-			JOIN chunks_fts fts ON c.id = fts.rowid
-			WHERE chunks_fts MATCH ?
-			ORDER BY rank
-			LIMIT ?
-		`, query, fetchLimit)
-	} else {
-		rows, err = s.db.QueryContext(ctx, `
-			SELECT c.id, c.document_id, c.parent_chunk_id, c.level, c.title, c.content, c.token_count
-			FROM chunks c
-			JOIN chunks_fts fts ON c.id = fts.rowid
-			JOIN documents d ON c.document_id = d.id
-			JOIN sources s ON d.source_id = s.id
-			WHERE chunks_fts MATCH ? AND s.language_id = ?
-			ORDER BY rank
-			LIMIT ?
-		`, query, languageID, fetchLimit)
-	}
+	where := table + " MATCH ?"
+	args := []any{match}
+	conds, condArgs := filter.conditions(chunkFilterColumns)
+	for _, cond := range conds {
+		where += " AND " + cond
+	}
+	args = append(append(args, condArgs...), fetchLimit)
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+chunkColumns+`, bm25(`+table+`)
+		FROM chunks c
+		JOIN `+table+` fts ON c.id = fts.rowid
+		JOIN documents d ON c.document_id = d.id
+		JOIN sources s ON d.source_id = s.id
+		WHERE `+where+`
+		ORDER BY rank
+		LIMIT ?
+	`, args...)
 	if err != nil {
 		return nil, fmt.Errorf("search chunks: %w", err)
 	}
 	defer rows.Close()
 
-	var chunks []*Chunk
+	var candidates []candidate
 	for rows.Next() {
-		var chunk Chunk
-		if err := rows.Scan(&chunk.ID, &chunk.DocumentID, &chunk.ParentChunkID, &chunk.Level, &chunk.Title, &chunk.Content, &chunk.TokenCount); err != nil {
+		var bm25 float64
+		chunk, err := scanChunk(rows, &bm25)
+		if err != nil {
 			return nil, fmt.Errorf("scan chunk: %w", err)
 		}
 		// Filter out low-quality chunks
-		if isQualityChunk(&chunk) {
-			chunks = append(chunks, &chunk)
-			if len(chunks) >= limit {
+		if isQualityChunk(chunk) {
+			// FTS5 reports BM25 negated so that ascending order is best first
+			candidates = append(candidates, candidate{chunk: chunk, score: -bm25})
+			if len(candidates) >= limit {
 				break
 			}
 		}
@@ -439,53 +657,35 @@ func (s *Store) SearchChunksFTS(ctx context.Context, query string, languageID in
 		return nil, fmt.Errorf("iterate chunks: %w", err)
 	}
 
-	return chunks, nil
+	return candidates, nil
 }
 
 // StoreEmbedding stores a vector embedding for a chunk.
 func (s *Store) StoreEmbedding(ctx context.Context, chunkID int64, embedding []float32) error {
-	blob := float32ToBytes(embedding)
-	_, err := s.db.ExecContext(ctx,
-		"INSERT INTO chunks_vec (chunk_id, embedding) VALUES (?, ?)",
-		chunkID, blob,
-	)
-	if err != nil {
-		return fmt.Errorf("insert embedding: %w", err)
+	if err := checkDimensions(ctx, s.db, embedding); err != nil {
+		return err
 	}
-	return nil
-}
 
-// SearchChunksVector searches chunks using vector similarity.
-// Pass languageID=0 to search all languages.
-func (s *Store) SearchChunksVector(ctx context.Context, queryVec []float32, languageID int64, limit int) ([]*Chunk, error) {
-	blob := float32ToBytes(queryVec)
-
-	var rows *sql.Rows
-	var err error
+	return insertVector(ctx, s.db, "chunks_vec", chunkID, embedding)
+}
 
-	if languageID == 0 {
-		rows, err = s.db.QueryContext(ctx, `
-			SELECT c.id, c.document_id, c.parent_chunk_id, c.level, c.title, c.content, c.token_count
-			FROM chunks c
-			JOIN chunks_vec v ON c.id = v.chunk_id
-			WHERE v.embedding MATCH ? AND k = ?
-			ORDER BY distance
-		`, blob, limit)
-	} else {
-		rows, err = s.db.QueryContext(ctx, `
-			SELECT c.id, c.document_id, c.parent_chunk_id, c.level, c.title, c.content, c.token_count
-			FROM chunks c
-			JOIN (
-				SELECT chunk_id, distance
-				FROM chunks_vec
-				WHERE embedding MATCH ? AND k = ?
-			) v ON c.id = v.chunk_id
-			JOIN documents d ON c.document_id = d.id
-			JOIN sources s ON d.source_id = s.id
-			WHERE s.language_id = ?
-			ORDER BY v.distance
-		`, blob, limit, languageID)
+// SearchChunksVector searches chunks using vector similarity, restricted by opts.
+func (s *Store) SearchChunksVector(ctx context.Context, queryVec []float32, opts SearchOptions, limit int) ([]*Chunk, error) {
+	if err := checkDimensions(ctx, s.db, queryVec); err != nil {
+		return nil, err
 	}
+	filter, err := s.searchFilter(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	knn, args := knnQuery(queryVec, filter, limit)
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+chunkColumns+`
+		FROM (`+knn+`) v
+		JOIN chunks c ON c.id = v.chunk_id
+		ORDER BY v.distance
+	`, args...)
 	if err != nil {
 		return nil, fmt.Errorf("search chunks vector: %w", err)
 	}
@@ -493,11 +693,11 @@ func (s *Store) SearchChunksVector(ctx context.Context, queryVec []float32, lang
 
 	var chunks []*Chunk
 	for rows.Next() {
-		var chunk Chunk
-		if err := rows.Scan(&chunk.ID, &chunk.DocumentID, &chunk.ParentChunkID, &chunk.Level, &chunk.Title, &chunk.Content, &chunk.TokenCount); err != nil {
+		chunk, err := scanChunk(rows)
+		if err != nil {
 			return nil, fmt.Errorf("scan chunk: %w", err)
 		}
-		chunks = append(chunks, &chunk)
+		chunks = append(chunks, chunk)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -507,15 +707,46 @@ func (s *Store) SearchChunksVector(ctx context.Context, queryVec []float32, lang
 	return chunks, nil
 }
 
-// SearchChunksVectorWithScore searches chunks using vector similarity and returns distances.
-// Pass languageID=0 to search all languages.
+// SearchChunksVectorWithScore searches chunks using vector similarity, restricted
+// and diversified by opts, and returns distances.
 // Results are filtered to exclude low-quality chunks (empty, too short, or title-only).
-func (s *Store) SearchChunksVectorWithScore(ctx context.Context, queryVec []float32, languageID int64, limit int) ([]*SearchResult, error) {
-	blob := float32ToBytes(queryVec)
+func (s *Store) SearchChunksVectorWithScore(ctx context.Context, queryVec []float32, opts SearchOptions, limit int) ([]*SearchResult, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	if err := checkDimensions(ctx, s.db, queryVec); err != nil {
+		return nil, err
+	}
+	filter, err := s.searchFilter(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
 
-	var rows *sql.Rows
-	var err error
+	// Diversifying needs alternatives to the top results to choose from
+	pool := limit
+	if opts.diversifies() {
+		pool = max(opts.Ranking.withDefaults().CandidatePool, limit)
+	}
+	candidates, err := s.vectorCandidates(ctx, queryVec, filter, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*SearchResult, len(candidates))
+	relevance := make([]float64, len(candidates))
+	for i, c := range candidates {
+		results[i] = &SearchResult{Chunk: c.chunk, Distance: c.distance}
+		relevance[i] = c.score
+	}
+	if err := s.attachSources(ctx, results); err != nil {
+		return nil, err
+	}
+	return s.diversify(ctx, results, relevance, opts, limit)
+}
 
+// vectorCandidates returns up to limit quality chunks nearest to queryVec,
+// best first, scored by cosine similarity.
+func (s *Store) vectorCandidates(ctx context.Context, queryVec []float32, filter *searchFilter, limit int) ([]candidate, error) {
 	// Request more results to account for quality filtering.
 	// For queries that match section headers semantically, many results may be
 	// title-only chunks that get filtered out.
@@ -524,48 +755,31 @@ func (s *Store) SearchChunksVectorWithScore(ctx context.Context, queryVec []floa
 		fetchLimit = 50
 	}
 
-	if languageID == 0 {
-		rows, err = s.db.QueryContext(ctx, `
-			SELECT c.id, c.document_id, c.parent_chunk_id, c.level, c.title, c.content, c.token_count, v.distance
-			FROM chunks c
-			JOIN chunks_vec v ON c.id = v.chunk_id
-			WHERE v.embedding MATCH ? AND k = ?
-			ORDER BY v.distance
-		`, blob, fetchLimit)
-	} else {
-		rows, err = s.db.QueryContext(ctx, `
-			SELECT c.id, c.document_id, c.parent_chunk_id, c.level, c.title, c.content, c.token_count, v.distance
-			FROM chunks c
-			JOIN (
-				SELECT chunk_id, distance
-				FROM chunks_vec
-				WHERE embedding MATCH ? AND k = ?
-			) v ON c.id = v.chunk_id
-			JOIN documents d ON c.document_id = d.id
-			JOIN sources src ON d.source_id = src.id
-			WHERE src.language_id = ?
-			ORDER BY v.distance
-		`, blob, fetchLimit, languageID)
-	}
+	knn, knnArgs := knnQuery(queryVec, filter, fetchLimit)
+	args := append([]any{float32ToBytes(queryVec)}, knnArgs...)
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+chunkColumns+`,
+			v.distance, COALESCE(1 - vec_distance_cosine(v.embedding, ?), 0) -- NULL for zero vectors
+		FROM (`+knn+`) v
+		JOIN chunks c ON c.id = v.chunk_id
+		ORDER BY v.distance
+	`, args...)
 	if err != nil {
 		return nil, fmt.Errorf("search chunks vector with score: %w", err)
 	}
 	defer rows.Close()
 
-	var results []*SearchResult
+	var candidates []candidate
 	for rows.Next() {
-		var chunk Chunk
-		var distance float64
-		if err := rows.Scan(&chunk.ID, &chunk.DocumentID, &chunk.ParentChunkID, &chunk.Level, &chunk.Title, &chunk.Content, &chunk.TokenCount, &distance); err != nil {
+		var distance, similarity float64
+		chunk, err := scanChunk(rows, &distance, &similarity)
+		if err != nil {
 			return nil, fmt.Errorf("scan search result: %w", err)
 		}
 		// Filter out low-quality chunks
-		if isQualityChunk(&chunk) {
-			results = append(results, &SearchResult{
-				Chunk:    &chunk,
-				Distance: distance,
-			})
-			if len(results) >= limit {
+		if isQualityChunk(chunk) {
+			candidates = append(candidates, candidate{chunk: chunk, distance: distance, score: similarity})
+			if len(candidates) >= limit {
 				break
 			}
 		}
@@ -575,78 +789,163 @@ func (s *Store) SearchChunksVectorWithScore(ctx context.Context, queryVec []floa
 		return nil, fmt.Errorf("iterate search results: %w", err)
 	}
 
-	return results, nil
+	return candidates, nil
 }
 
-// SearchChunksHybrid combines vector similarity and FTS5 search using Reciprocal Rank Fusion.
-// Pass languageID=0 to search all languages. If textQuery is empty, only vector search is used.
-func (s *Store) SearchChunksHybrid(ctx context.Context, queryVec []float32, textQuery string, languageID int64, limit int) ([]*SearchResult, error) {
-	// Get vector results
-	vectorResults, err := s.SearchChunksVectorWithScore(ctx, queryVec, languageID, limit)
+// SearchChunksHybrid combines vector similarity, FTS5 search and the code
+// identifier index, fusing the rankings as configured by opts.Ranking and
+// weighting the result by source tier. All searches are restricted by opts,
+// and the fused results are diversified as it sets.
+// If textQuery has no searchable terms or full-text search fails, only the
+// vector ranking is used.
+func (s *Store) SearchChunksHybrid(ctx context.Context, queryVec []float32, textQuery string, opts SearchOptions, limit int) ([]*SearchResult, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	ranking := opts.Ranking.withDefaults()
+
+	if err := checkDimensions(ctx, s.db, queryVec); err != nil {
+		return nil, err
+	}
+	filter, err := s.searchFilter(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fuse over a wider pool than the final limit, so that chunks ranked
+	// moderately by both retrievers can beat chunks only one of them found
+	pool := max(ranking.CandidatePool, limit)
+	vectorResults, err := s.vectorCandidates(ctx, queryVec, filter, pool)
 	if err != nil {
 		return nil, fmt.Errorf("vector search: %w", err)
 	}
+	lists := []retrieval{{candidates: vectorResults, weight: *ranking.VectorWeight}}
 
-	// If no text query, return vector results only
-	if textQuery == "" {
-		return vectorResults, nil
+	if match := ftsMatch(textQuery, opts); match != "" {
+		text, err := s.textRetrievals(ctx, match, filter, pool, ranking)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, text...)
 	}
 
-	// Get FTS results
-	ftsChunks, err := s.SearchChunksFTS(ctx, textQuery, languageID, limit)
-	if err != nil {
-		return nil, fmt.Errorf("fts search: %w", err)
+	var results []*SearchResult
+	switch ranking.Fusion {
+	case FusionLinear:
+		results = fuseLinear(lists)
+	default:
+		results = fuseRRF(lists, ranking)
 	}
 
-	// Use Reciprocal Rank Fusion to combine results
-	// RRF score = sum(1 / (k + rank)) for each ranking list where the document appears
-	// Using k=1 for better score differentiation in small result sets
-	const k = 1.0
+	// Weight scores by source tier so official documentation outranks
+	// community content of similar relevance
+	if err := s.attachSources(ctx, results); err != nil {
+		return nil, err
+	}
+	weight := ranking.tierWeight()
+	for _, r := range results {
+		r.Distance = 1.0 - (1.0-r.Distance)*weight(r.Source.Tier)
+	}
 
-	// Max possible score is 2 * 1/(k+1) = 1.0 when item is rank 1 in both sources
-	maxScore := 2.0 * (1.0 / (k + 1.0))
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Distance != results[j].Distance {
+			return results[i].Distance < results[j].Distance
+		}
+		return results[i].Chunk.ID < results[j].Chunk.ID // Deterministic ties
+	})
 
-	// Build a map of chunk IDs to RRF scores
-	scores := make(map[int64]float64)
-	chunks := make(map[int64]*Chunk)
+	// The fused, tier-weighted score is the relevance of a hybrid result
+	relevance := make([]float64, len(results))
+	for i, r := range results {
+		relevance[i] = 1 - r.Distance
+	}
+	return s.diversify(ctx, results, relevance, opts, limit)
+}
 
-	// Add vector results
-	for i, r := range vectorResults {
-		rank := float64(i + 1)
-		scores[r.Chunk.ID] += 1.0 / (k + rank)
-		chunks[r.Chunk.ID] = r.Chunk
+// textRetrievals returns the full-text ranking for match, followed by the
+// code identifier ranking if any identifiers matched. If full-text search
+// fails it returns neither, so that a malformed advanced query does not
+// cost the caller the semantic results.
+func (s *Store) textRetrievals(ctx context.Context, match string, filter *searchFilter, pool int, ranking Ranking) ([]retrieval, error) {
+	ftsResults, err := s.ftsCandidates(ctx, "chunks_fts", match, filter, pool)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("fts search: %w", err)
+		}
+		return nil, nil
 	}
+	lists := []retrieval{{candidates: ftsResults, weight: *ranking.TextWeight, bm25: true}}
 
-	// Add FTS results
-	for i, c := range ftsChunks {
-		rank := float64(i + 1)
-		scores[c.ID] += 1.0 / (k + rank)
-		if _, exists := chunks[c.ID]; !exists {
-			chunks[c.ID] = c
+	symbolResults, err := s.ftsCandidates(ctx, "chunks_symbols", match, filter, pool)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("symbol search: %w", err)
 		}
+		// Advanced queries may name columns of chunks_fts that the symbol
+		// index lacks; rank without it
+		return lists, nil
+	}
+	// Most queries name no identifiers; counting the symbol ranking only
+	// when it matched keeps their scores comparable to before
+	if len(symbolResults) > 0 {
+		lists = append(lists, retrieval{candidates: symbolResults, weight: *ranking.SymbolWeight, bm25: true})
 	}
+	return lists, nil
+}
 
-	// Convert to results slice and sort by RRF score (higher is better)
-	// Normalize scores to 0-1 range for meaningful display
-	var results []*SearchResult
-	for id, score := range scores {
-		normalizedScore := score / maxScore // 0-1 range, higher = better
-		results = append(results, &SearchResult{
-			Chunk:    chunks[id],
-			Distance: 1.0 - normalizedScore, // Convert to distance (lower = better, 0 = perfect match)
-		})
+// attachSources sets the Source and Citation of each result.
+func (s *Store) attachSources(ctx context.Context, results []*SearchResult) error {
+	if len(results) == 0 {
+		return nil
 	}
 
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Distance < results[j].Distance
-	})
+	args := make([]any, len(results))
+	for i, r := range results {
+		args[i] = r.Chunk.ID
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT c.id, c.anchor, d.path, l.name, s.id, s.language_id, s.name, s.type, s.url, s.tier, s.revision
+		FROM chunks c
+		JOIN documents d ON c.document_id = d.id
+		JOIN sources s ON d.source_id = s.id
+		JOIN languages l ON s.language_id = l.id
+		WHERE c.id IN (`+placeholders(len(args))+`)
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("query result sources: %w", err)
+	}
+	defer rows.Close()
 
-	// Limit results
-	if len(results) > limit {
-		results = results[:limit]
+	chunkSources := make(map[int64]*Source, len(results))
+	citations := make(map[int64]*Citation, len(results))
+	sources := make(map[int64]*Source)
+	for rows.Next() {
+		var chunkID int64
+		var anchor, path, language string
+		var src Source
+		if err := rows.Scan(&chunkID, &anchor, &path, &language, &src.ID, &src.LanguageID, &src.Name, &src.Type, &src.URL, &src.Tier, &src.Revision); err != nil {
+			return fmt.Errorf("scan result source: %w", err)
+		}
+		if _, ok := sources[src.ID]; !ok {
+			sources[src.ID] = &src
+		}
+		chunkSources[chunkID] = sources[src.ID]
+		citations[chunkID] = newCitation(language, sources[src.ID], path, anchor)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate result sources: %w", err)
 	}
 
-	return results, nil
+	for _, r := range results {
+		r.Source = chunkSources[r.Chunk.ID]
+		r.Citation = citations[r.Chunk.ID]
+		if r.Source == nil {
+			// Deleted between the search and this lookup
+			r.Source = &Source{}
+			r.Citation = &Citation{}
+		}
+	}
+	return nil
 }
 
 // Stats represents statistics about the knowledge base.
@@ -656,6 +955,9 @@ type Stats struct {
 	Documents  int64
 	Chunks     int64
 	Embeddings int64
+
+	EmbeddingModel      string // Empty until the first embedding is stored
+	EmbeddingDimensions int
 }
 
 // GetStats returns statistics about the knowledge base.
@@ -687,6 +989,15 @@ func (s *Store) GetStats(ctx context.Context) (*Stats, error) {
 		return nil, fmt.Errorf("count embeddings: %w", err)
 	}
 
+	model, err := s.EmbeddingModel(ctx)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	if model != nil {
+		stats.EmbeddingModel = model.Name
+		stats.EmbeddingDimensions = model.Dimensions
+	}
+
 	return stats, nil
 }
 
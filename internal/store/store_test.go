@@ -2,6 +2,8 @@ package store_test
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/jamesainslie/grimoire/internal/store"
@@ -292,6 +294,52 @@ func TestStore_ListSources(t *testing.T) {
 	}
 }
 
+func TestStore_SetSourceTier(t *testing.T) {
+	t.Parallel()
+
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	lang, _ := s.CreateLanguage(ctx, "go", "Go")
+	src, _ := s.CreateSource(ctx, lang.ID, "go-wiki", "git", "https://github.com/golang/wiki")
+	embedding := make([]float32, 1024)
+	embedding[0] = 1
+	_, err := s.ReplaceDocument(ctx, &store.Document{SourceID: src.ID, Path: "CodeReviewComments.md"}, []store.NewChunk{{
+		Level:     "section",
+		Title:     "Contexts",
+		Content:   strings.Repeat("Values of the context.Context type carry deadlines and cancellation. ", 2),
+		Embedding: embedding,
+	}})
+	if err != nil {
+		t.Fatalf("ReplaceDocument() error = %v", err)
+	}
+
+	if err := s.SetSourceTier(ctx, src.ID, 1); err != nil {
+		t.Fatalf("SetSourceTier() error = %v", err)
+	}
+
+	got, err := s.GetSource(ctx, lang.ID, "go-wiki")
+	if err != nil {
+		t.Fatalf("GetSource() error = %v", err)
+	}
+	if got.Tier != 1 {
+		t.Errorf("GetSource().Tier = %d, want 1", got.Tier)
+	}
+
+	// The vector index must see the new tier too
+	results, err := s.SearchChunksVectorWithScore(ctx, embedding, store.SearchOptions{Tiers: []int{1}}, 5)
+	if err != nil {
+		t.Fatalf("SearchChunksVectorWithScore() error = %v", err)
+	}
+	if len(results) != 1 || results[0].Source.Tier != 1 {
+		t.Errorf("SearchChunksVectorWithScore(tier 1) = %d results, want 1 from a tier 1 source", len(results))
+	}
+
+	if err := s.SetSourceTier(ctx, src.ID+1, 1); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("SetSourceTier(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
 func TestStore_CreateDocument(t *testing.T) {
 	t.Parallel()
 
@@ -348,6 +396,119 @@ func TestStore_CreateChunk(t *testing.T) {
 	}
 }
 
+func TestStore_ReplaceDocument(t *testing.T) {
+	t.Parallel()
+
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	lang, _ := s.CreateLanguage(ctx, "go", "Go")
+	src, _ := s.CreateSource(ctx, lang.ID, "uber-guide", "git", "https://github.com/uber-go/guide")
+
+	embedding := make([]float32, 1024)
+	embedding[0] = 1.0
+	root := 0
+
+	doc, err := s.ReplaceDocument(ctx, &store.Document{SourceID: src.ID, Path: "style.md", Title: "Style", ContentHash: "v1"}, []store.NewChunk{
+		{Level: "summary", Title: "Style", Content: "Uber Go Style Guide", Embedding: embedding},
+		{ParentIndex: &root, Level: "section", Title: "Goroutines", Content: strings.Repeat("Never fire-and-forget goroutines. ", 5), Embedding: embedding},
+	})
+	if err != nil {
+		t.Fatalf("ReplaceDocument(v1) error = %v", err)
+	}
+
+	// Replacing the document swaps out chunks, FTS rows and embeddings
+	updated, err := s.ReplaceDocument(ctx, &store.Document{SourceID: src.ID, Path: "style.md", Title: "Style", ContentHash: "v2"}, []store.NewChunk{
+		{Level: "summary", Title: "Style", Content: "Uber Go Style Guide", Embedding: embedding},
+		{ParentIndex: &root, Level: "section", Title: "Mutexes", Content: strings.Repeat("Zero-value mutexes are valid and ready to use. ", 5), Embedding: embedding},
+	})
+	if err != nil {
+		t.Fatalf("ReplaceDocument(v2) error = %v", err)
+	}
+	if updated.ID != doc.ID {
+		t.Errorf("ReplaceDocument() ID = %d, want existing ID %d", updated.ID, doc.ID)
+	}
+
+	stats, _ := s.GetStats(ctx)
+	if stats.Documents != 1 || stats.Chunks != 2 || stats.Embeddings != 2 {
+		t.Errorf("stats = %+v, want 1 document, 2 chunks, 2 embeddings", stats)
+	}
+
+	old, err := s.SearchChunksFTS(ctx, "goroutines", store.SearchOptions{}, 10)
+	if err != nil {
+		t.Fatalf("SearchChunksFTS(goroutines) error = %v", err)
+	}
+	if len(old) != 0 {
+		t.Errorf("SearchChunksFTS(goroutines) = %d results, want 0 after replace", len(old))
+	}
+
+	current, err := s.SearchChunksFTS(ctx, "mutexes", store.SearchOptions{}, 10)
+	if err != nil {
+		t.Fatalf("SearchChunksFTS(mutexes) error = %v", err)
+	}
+	if len(current) != 1 {
+		t.Errorf("SearchChunksFTS(mutexes) = %d results, want 1", len(current))
+	}
+
+	got, err := s.GetDocumentByPath(ctx, src.ID, "style.md")
+	if err != nil {
+		t.Fatalf("GetDocumentByPath() error = %v", err)
+	}
+	if got.ContentHash != "v2" {
+		t.Errorf("GetDocumentByPath() ContentHash = %q, want v2", got.ContentHash)
+	}
+}
+
+func TestStore_PruneDocuments(t *testing.T) {
+	t.Parallel()
+
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	lang, _ := s.CreateLanguage(ctx, "go", "Go")
+	wiki, _ := s.CreateSource(ctx, lang.ID, "go-wiki", "git", "https://github.com/golang/wiki")
+	guide, _ := s.CreateSource(ctx, lang.ID, "uber-guide", "git", "https://github.com/uber-go/guide")
+
+	embedding := make([]float32, 1024)
+	embedding[0] = 1.0
+	content := strings.Repeat("Handle every error exactly once, either by logging or returning it. ", 3)
+
+	for _, doc := range []*store.Document{
+		{SourceID: wiki.ID, Path: "CodeReviewComments.md"},
+		{SourceID: wiki.ID, Path: "Renamed.md"},
+		{SourceID: guide.ID, Path: "style.md"},
+	} {
+		_, err := s.ReplaceDocument(ctx, doc, []store.NewChunk{
+			{Level: "section", Title: "Errors", Content: content, Embedding: embedding},
+		})
+		if err != nil {
+			t.Fatalf("ReplaceDocument(%s) error = %v", doc.Path, err)
+		}
+	}
+
+	removed, err := s.PruneDocuments(ctx, wiki.ID, []string{"CodeReviewComments.md"})
+	if err != nil {
+		t.Fatalf("PruneDocuments() error = %v", err)
+	}
+	if len(removed) != 1 || removed[0] != "Renamed.md" {
+		t.Errorf("PruneDocuments() removed = %v, want [Renamed.md]", removed)
+	}
+
+	// Other sources are untouched and derived rows are gone
+	stats, _ := s.GetStats(ctx)
+	if stats.Documents != 2 || stats.Chunks != 2 || stats.Embeddings != 2 {
+		t.Errorf("stats = %+v, want 2 documents, 2 chunks, 2 embeddings", stats)
+	}
+
+	results, err := s.SearchChunksFTS(ctx, "error", store.SearchOptions{}, 10)
+	if err != nil {
+		t.Fatalf("SearchChunksFTS() error = %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("SearchChunksFTS() = %d results, want 2", len(results))
+	}
+}
+
 func TestStore_SearchChunks(t *testing.T) {
 	t.Parallel()
 
@@ -364,7 +525,7 @@ func TestStore_SearchChunks(t *testing.T) {
 	_, _ = s.CreateChunk(ctx, doc.ID, nil, "section", "Naming", "Use short variable names in narrow scope", 25)
 
 	// Search for "error"
-	results, err := s.SearchChunksFTS(ctx, "error", 0, 10)
+	results, err := s.SearchChunksFTS(ctx, "error", store.SearchOptions{}, 10)
 	if err != nil {
 		t.Fatalf("SearchChunksFTS() error = %v", err)
 	}
@@ -411,7 +572,7 @@ func TestStore_VectorSearch(t *testing.T) {
 	queryVec[0] = 0.9
 	queryVec[1] = 0.1
 
-	results, err := s.SearchChunksVector(ctx, queryVec, 0, 10)
+	results, err := s.SearchChunksVector(ctx, queryVec, store.SearchOptions{}, 10)
 	if err != nil {
 		t.Fatalf("SearchChunksVector() error = %v", err)
 	}
@@ -451,7 +612,7 @@ func TestStore_VectorSearchWithLanguageFilter(t *testing.T) {
 	_ = s.StoreEmbedding(ctx, rustChunk.ID, embedding)
 
 	// Search all languages - should find both
-	allResults, err := s.SearchChunksVector(ctx, embedding, 0, 10)
+	allResults, err := s.SearchChunksVector(ctx, embedding, store.SearchOptions{}, 10)
 	if err != nil {
 		t.Fatalf("SearchChunksVector(all) error = %v", err)
 	}
@@ -460,7 +621,7 @@ func TestStore_VectorSearchWithLanguageFilter(t *testing.T) {
 	}
 
 	// Search Go only - should find only Go chunk
-	goResults, err := s.SearchChunksVector(ctx, embedding, goLang.ID, 10)
+	goResults, err := s.SearchChunksVector(ctx, embedding, store.SearchOptions{LanguageID: goLang.ID}, 10)
 	if err != nil {
 		t.Fatalf("SearchChunksVector(go) error = %v", err)
 	}
@@ -500,7 +661,7 @@ func TestStore_SearchChunksVectorWithScore(t *testing.T) {
 	queryVec := make([]float32, 1024)
 	queryVec[0] = 0.95
 
-	results, err := s.SearchChunksVectorWithScore(ctx, queryVec, 0, 10)
+	results, err := s.SearchChunksVectorWithScore(ctx, queryVec, store.SearchOptions{}, 10)
 	if err != nil {
 		t.Fatalf("SearchChunksVectorWithScore() error = %v", err)
 	}
@@ -564,7 +725,7 @@ func TestStore_SearchChunksHybrid(t *testing.T) {
 	queryVec := make([]float32, 1024)
 	queryVec[0] = 1.0
 
-	results, err := s.SearchChunksHybrid(ctx, queryVec, "error", 0, 10)
+	results, err := s.SearchChunksHybrid(ctx, queryVec, "error", store.SearchOptions{}, 10)
 	if err != nil {
 		t.Fatalf("SearchChunksHybrid() error = %v", err)
 	}
@@ -605,7 +766,7 @@ func TestStore_SearchChunksHybrid_VectorOnly(t *testing.T) {
 	queryVec := make([]float32, 1024)
 	queryVec[0] = 0.95
 
-	results, err := s.SearchChunksHybrid(ctx, queryVec, "", 0, 10)
+	results, err := s.SearchChunksHybrid(ctx, queryVec, "", store.SearchOptions{}, 10)
 	if err != nil {
 		t.Fatalf("SearchChunksHybrid() error = %v", err)
 	}
@@ -0,0 +1,143 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The default FTS5 tokenizer splits "http.HandlerFunc" into "http" and
+// "handlerfunc" and cannot match "HandlerFunc" from "handler func", so exact
+// API-name lookups rank poorly against prose. chunks_symbols indexes the code
+// identifiers of each chunk separately, with a tokenizer that keeps dots and
+// underscores, as the whole identifier plus its dotted, snake_case and
+// camelCase parts. Hybrid search uses it as a third retriever.
+
+// identifierPattern matches identifiers, optionally qualified with dots.
+var identifierPattern = regexp.MustCompile(`[\p{L}_][\p{L}\p{N}_]*(?:\.[\p{L}_][\p{L}\p{N}_]*)*`)
+
+// createSymbolIndex is migration 8. It creates chunks_symbols and indexes
+// existing chunks.
+func createSymbolIndex(ctx context.Context, tx *sql.Tx) error {
+	err := execStatements(
+		`CREATE VIRTUAL TABLE chunks_symbols USING fts5(
+			symbols,
+			tokenize = "unicode61 tokenchars '._'"
+		)`,
+		`CREATE TRIGGER chunks_symbols_ad AFTER DELETE ON chunks BEGIN
+			DELETE FROM chunks_symbols WHERE rowid = old.id;
+		END`,
+	)(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT id, title, content FROM chunks")
+	if err != nil {
+		return fmt.Errorf("query chunks: %w", err)
+	}
+	type pending struct {
+		id             int64
+		title, content string
+	}
+	var chunks []pending
+	for rows.Next() {
+		var c pending
+		var title sql.NullString
+		if err := rows.Scan(&c.id, &title, &c.content); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan chunk: %w", err)
+		}
+		c.title = title.String
+		chunks = append(chunks, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate chunks: %w", err)
+	}
+
+	for _, c := range chunks {
+		if err := insertSymbols(ctx, tx, c.id, c.title, c.content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertSymbols indexes the code identifiers of a chunk in chunks_symbols.
+// Chunks without identifiers get no row.
+func insertSymbols(ctx context.Context, ex execer, chunkID int64, title, content string) error {
+	symbols := Symbols(title + "\n" + content)
+	if symbols == "" {
+		return nil
+	}
+	if _, err := ex.ExecContext(ctx, "INSERT INTO chunks_symbols (rowid, symbols) VALUES (?, ?)", chunkID, symbols); err != nil {
+		return fmt.Errorf("insert symbols: %w", err)
+	}
+	return nil
+}
+
+// Symbols returns the search tokens for the code identifiers in text,
+// separated by spaces. Plain words are left to the main full-text index; an
+// identifier counts as code if it is qualified with a dot, contains an
+// underscore or mixes case after its first letter. For "http.HandlerFunc" the
+// tokens are "http.HandlerFunc", "http", "HandlerFunc", "Handler" and "Func".
+func Symbols(text string) string {
+	var tokens []string
+	for _, ident := range identifierPattern.FindAllString(text, -1) {
+		if !codeLike(ident) {
+			continue
+		}
+		tokens = append(tokens, ident)
+
+		parts := strings.Split(ident, ".")
+		for _, part := range parts {
+			if len(parts) > 1 {
+				tokens = append(tokens, part)
+			}
+			if words := splitIdentifier(part); len(words) > 1 {
+				tokens = append(tokens, words...)
+			}
+		}
+	}
+	return strings.Join(tokens, " ")
+}
+
+// codeLike reports whether ident looks like a code identifier rather than a
+// word of prose.
+func codeLike(ident string) bool {
+	if strings.ContainsAny(ident, "._") {
+		return true
+	}
+	_, size := utf8.DecodeRuneInString(ident)
+	rest := ident[size:]
+	return strings.IndexFunc(rest, unicode.IsUpper) >= 0 && strings.IndexFunc(ident, unicode.IsLower) >= 0
+}
+
+// splitIdentifier splits an identifier into its snake_case and camelCase
+// words. Runs of capitals are kept together as an acronym, so
+// "parseHTTPRequest" yields "parse", "HTTP" and "Request".
+func splitIdentifier(ident string) []string {
+	var words []string
+	for _, part := range strings.Split(ident, "_") {
+		runes := []rune(part)
+		start := 0
+		for i := 1; i < len(runes); i++ {
+			prev, cur := runes[i-1], runes[i]
+			lowerToUpper := (unicode.IsLower(prev) || unicode.IsDigit(prev)) && unicode.IsUpper(cur)
+			acronymEnd := unicode.IsUpper(prev) && unicode.IsUpper(cur) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if lowerToUpper || acronymEnd {
+				words = append(words, string(runes[start:i]))
+				start = i
+			}
+		}
+		if start < len(runes) {
+			words = append(words, string(runes[start:]))
+		}
+	}
+	return words
+}
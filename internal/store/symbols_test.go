@@ -0,0 +1,155 @@
+package store_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jamesainslie/grimoire/internal/store"
+)
+
+func TestSymbols(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "use sync.WaitGroup", want: "sync.WaitGroup sync WaitGroup Wait Group"},
+		{input: "call t.Parallel()", want: "t.Parallel t Parallel"},
+		{input: "parseHTTPRequest", want: "parseHTTPRequest parse HTTP Request"},
+		{input: "max_tokens", want: "max_tokens max tokens"},
+		{input: "Float32ToBytes", want: "Float32ToBytes Float32 To Bytes"},
+		{input: "Plain prose, Go and HTTP only.", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+
+			if got := store.Symbols(tt.input); got != tt.want {
+				t.Errorf("Symbols(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStore_SearchChunksSymbols(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	lang, _ := s.CreateLanguage(ctx, "go", "Go")
+	src, _ := s.CreateSource(ctx, lang.ID, "go-docs", "git", "https://github.com/golang/go")
+
+	docs := []struct {
+		path    string
+		content string
+		axis    int
+	}{
+		{
+			path:    "prose.md",
+			content: strings.Repeat("To wait for a group of goroutines, sync them at a barrier and wait until the whole group is done. ", 2),
+			axis:    0,
+		},
+		{
+			path:    "waitgroup.md",
+			content: strings.Repeat("Call wg.Add before starting each goroutine and defer wg.Done; a sync.WaitGroup blocks in wg.Wait. ", 2),
+			axis:    1,
+		},
+		{
+			path:    "handler.md",
+			content: strings.Repeat("An http.HandlerFunc adapts an ordinary function to the http.Handler interface for ServeMux. ", 2),
+			axis:    2,
+		},
+		{
+			path:    "testing.md",
+			content: strings.Repeat("Mark independent tests with t.Parallel() so that go test runs them concurrently with others. ", 2),
+			axis:    3,
+		},
+	}
+	paths := make(map[int64]string)
+	for _, d := range docs {
+		embedding := make([]float32, 1024)
+		embedding[d.axis] = 1
+		doc, err := s.ReplaceDocument(ctx, &store.Document{SourceID: src.ID, Path: d.path}, []store.NewChunk{{
+			Level:     "section",
+			Title:     d.path,
+			Content:   d.content,
+			Embedding: embedding,
+		}})
+		if err != nil {
+			t.Fatalf("ReplaceDocument(%s) error = %v", d.path, err)
+		}
+		paths[doc.ID] = d.path
+	}
+
+	pathOf := func(c *store.Chunk) string { return paths[c.DocumentID] }
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "sync.WaitGroup", want: "waitgroup.md"},
+		{query: "WaitGroup", want: "waitgroup.md"},
+		{query: "HandlerFunc", want: "handler.md"},
+		{query: "http.HandlerFunc", want: "handler.md"},
+		{query: "t.Parallel()", want: "testing.md"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			chunks, err := s.SearchChunksSymbols(ctx, tt.query, store.SearchOptions{}, 10)
+			if err != nil {
+				t.Fatalf("SearchChunksSymbols(%q) error = %v", tt.query, err)
+			}
+			if len(chunks) == 0 {
+				t.Fatalf("SearchChunksSymbols(%q) = no results, want %s", tt.query, tt.want)
+			}
+			if got := pathOf(chunks[0]); got != tt.want {
+				t.Errorf("SearchChunksSymbols(%q)[0] = %s, want %s", tt.query, got, tt.want)
+			}
+
+			// The query vector favours the prose chunk, which shares words
+			// but not identifiers with the query
+			queryVec := make([]float32, 1024)
+			queryVec[0] = 1
+			results, err := s.SearchChunksHybrid(ctx, queryVec, tt.query, store.SearchOptions{}, 10)
+			if err != nil {
+				t.Fatalf("SearchChunksHybrid(%q) error = %v", tt.query, err)
+			}
+			if len(results) == 0 {
+				t.Fatalf("SearchChunksHybrid(%q) = no results, want %s", tt.query, tt.want)
+			}
+			if got := pathOf(results[0].Chunk); got != tt.want {
+				t.Errorf("SearchChunksHybrid(%q)[0] = %s, want %s", tt.query, got, tt.want)
+			}
+		})
+	}
+
+	// camelCase parts match identifiers written as one word
+	chunks, err := s.SearchChunksSymbols(ctx, "wait group", store.SearchOptions{}, 10)
+	if err != nil {
+		t.Fatalf("SearchChunksSymbols(wait group) error = %v", err)
+	}
+	if len(chunks) != 1 || pathOf(chunks[0]) != "waitgroup.md" {
+		t.Errorf("SearchChunksSymbols(wait group) = %d results, want waitgroup.md only", len(chunks))
+	}
+
+	// Deleting a document removes its identifiers
+	doc, err := s.GetDocumentByPath(ctx, src.ID, "waitgroup.md")
+	if err != nil {
+		t.Fatalf("GetDocumentByPath() error = %v", err)
+	}
+	if err := s.DeleteDocument(ctx, doc.ID); err != nil {
+		t.Fatalf("DeleteDocument() error = %v", err)
+	}
+	chunks, err = s.SearchChunksSymbols(ctx, "sync.WaitGroup", store.SearchOptions{}, 10)
+	if err != nil {
+		t.Fatalf("SearchChunksSymbols() after delete error = %v", err)
+	}
+	if len(chunks) != 0 {
+		t.Errorf("SearchChunksSymbols() after delete = %d results, want 0", len(chunks))
+	}
+}
@@ -0,0 +1,184 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Heading is a heading of a document, recorded in the topic index.
+type Heading struct {
+	Level  int // 1 for a top-level heading
+	Text   string
+	Anchor string
+}
+
+// Topic is a document title or heading in the topic index, with the
+// headings nested under it. Headings with the same path in several
+// documents are one topic.
+type Topic struct {
+	Name      string
+	Path      []string // Names from the root topic down to this one
+	Documents int      // Documents covering the topic
+	Children  []*Topic // Subtopics, most widely covered first
+}
+
+// TopicOptions selects part of the topic index.
+type TopicOptions struct {
+	LanguageID int64    // Only topics of this language; 0 for all
+	Sources    []string // Only topics of these sources, by name; empty for all
+	Parent     []string // Only topics under this one, given as its path
+	Depth      int      // Levels of topics below Parent to return; 0 for all
+}
+
+// createTopicIndex creates the topic index and fills it from the titles and
+// breadcrumbs of documents indexed so far. Breadcrumbs miss headings without
+// a chunk of their own; the next ingest re-indexes every document and
+// records them.
+func createTopicIndex(ctx context.Context, tx *sql.Tx) error {
+	return execStatements(
+		`CREATE TABLE topics (
+			id INTEGER PRIMARY KEY,
+			document_id INTEGER NOT NULL REFERENCES documents(id),
+			path TEXT NOT NULL,
+			UNIQUE(document_id, path)
+		)`,
+		`CREATE INDEX idx_topics_path ON topics(path)`,
+		`CREATE TRIGGER documents_topics_ad AFTER DELETE ON documents BEGIN
+			DELETE FROM topics WHERE document_id = old.id;
+		END`,
+		`INSERT INTO topics (document_id, path)
+			SELECT id, title FROM documents WHERE COALESCE(title, '') != ''`,
+		`INSERT OR IGNORE INTO topics (document_id, path)
+			SELECT document_id, breadcrumbs FROM chunks
+			WHERE level != 'summary' AND breadcrumbs != ''
+			ORDER BY id`,
+	)(ctx, tx)
+}
+
+// replaceTopics replaces the topics of a document with its title and the
+// paths of its headings.
+func replaceTopics(ctx context.Context, tx *sql.Tx, documentID int64, title string, headings []Heading) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM topics WHERE document_id = ?", documentID); err != nil {
+		return fmt.Errorf("delete topics: %w", err)
+	}
+	for _, path := range topicPaths(title, headings) {
+		_, err := tx.ExecContext(ctx,
+			"INSERT OR IGNORE INTO topics (document_id, path) VALUES (?, ?)",
+			documentID, strings.Join(path, "\n"),
+		)
+		if err != nil {
+			return fmt.Errorf("insert topic: %w", err)
+		}
+	}
+	return nil
+}
+
+// topicPaths returns the path of the title and of each heading, tracking the
+// heading hierarchy as the chunker does for breadcrumbs: a top-level heading
+// takes the title's place.
+func topicPaths(title string, headings []Heading) [][]string {
+	stack := []string{title}
+	paths := [][]string{{title}}
+	for _, h := range headings {
+		level := max(h.Level, 1)
+		if len(stack) > level {
+			stack = stack[:level]
+		}
+		if len(stack) < level {
+			stack = append(stack, h.Text)
+		} else {
+			stack[level-1] = h.Text
+		}
+		paths = append(paths, slices.Clone(stack))
+	}
+	return paths
+}
+
+// ListTopics returns the topic index as a tree of document titles and the
+// headings under them, with the number of documents covering each topic.
+// Topics are ordered by how many documents cover them, then by first
+// appearance.
+func (s *Store) ListTopics(ctx context.Context, opts TopicOptions) ([]*Topic, error) {
+	query := `
+		SELECT t.path, COUNT(DISTINCT t.document_id)
+		FROM topics t
+		JOIN documents d ON t.document_id = d.id
+		JOIN sources s ON d.source_id = s.id
+		WHERE 1 = 1`
+	var args []any
+	if opts.LanguageID != 0 {
+		query += " AND s.language_id = ?"
+		args = append(args, opts.LanguageID)
+	}
+	if len(opts.Sources) > 0 {
+		ids, err := s.sourceIDs(ctx, opts.LanguageID, opts.Sources)
+		if err != nil {
+			return nil, err
+		}
+		query += " AND s.id IN (" + placeholders(len(ids)) + ")"
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+	if len(opts.Parent) > 0 {
+		// Paths under the parent sort between it plus a newline and it plus
+		// the next byte
+		prefix := strings.Join(opts.Parent, "\n")
+		query += " AND t.path >= ? AND t.path < ?"
+		args = append(args, prefix+"\n", prefix+"\x0b")
+	}
+	query += " GROUP BY t.path ORDER BY MIN(t.id)"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query topics: %w", err)
+	}
+	defer rows.Close()
+
+	var roots []*Topic
+	topics := make(map[string]*Topic)
+	for rows.Next() {
+		var path string
+		var documents int
+		if err := rows.Scan(&path, &documents); err != nil {
+			return nil, fmt.Errorf("scan topic: %w", err)
+		}
+
+		names := strings.Split(path, "\n")
+		depth := len(names) - len(opts.Parent)
+		if opts.Depth > 0 && depth > opts.Depth {
+			continue
+		}
+		topic := &Topic{Name: names[len(names)-1], Path: names, Documents: documents}
+		topics[path] = topic
+
+		// Parents come first, as a document's title and headings precede
+		// the headings nested under them
+		if parent, ok := topics[strings.Join(names[:len(names)-1], "\n")]; ok && depth > 1 {
+			parent.Children = append(parent.Children, topic)
+		} else {
+			roots = append(roots, topic)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate topics: %w", err)
+	}
+
+	sortTopics(roots)
+	return roots, nil
+}
+
+// sortTopics orders topics and their subtopics by the number of documents
+// covering them, keeping the order of topics covered equally.
+func sortTopics(topics []*Topic) {
+	slices.SortStableFunc(topics, func(a, b *Topic) int {
+		return cmp.Compare(b.Documents, a.Documents)
+	})
+	for _, t := range topics {
+		sortTopics(t.Children)
+	}
+}
@@ -0,0 +1,124 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jamesainslie/grimoire/internal/store"
+)
+
+func TestStore_ListTopics(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	goLang, _ := s.CreateLanguage(ctx, "go", "Go")
+	rustLang, _ := s.CreateLanguage(ctx, "rust", "Rust")
+	wiki, _ := s.CreateSource(ctx, goLang.ID, "go-wiki", "git", "https://github.com/golang/wiki")
+	guide, _ := s.CreateSource(ctx, goLang.ID, "uber-guide", "git", "https://github.com/uber-go/guide")
+	book, _ := s.CreateSource(ctx, rustLang.ID, "book", "git", "https://github.com/rust-lang/book")
+
+	documents := []struct {
+		sourceID int64
+		path     string
+		title    string
+		headings []store.Heading
+	}{
+		{wiki.ID, "errors.md", "Errors", []store.Heading{
+			{Level: 1, Text: "Errors"},
+			{Level: 2, Text: "Wrapping"},
+			{Level: 3, Text: "Sentinels"},
+			{Level: 2, Text: "Panics"},
+		}},
+		{guide.ID, "errors.md", "Errors", []store.Heading{
+			{Level: 2, Text: "Wrapping"},
+		}},
+		{guide.ID, "style.md", "Style", []store.Heading{
+			{Level: 2, Text: "Naming"},
+		}},
+		{book.ID, "errors.md", "Errors", []store.Heading{
+			{Level: 2, Text: "Result"},
+		}},
+	}
+	for _, d := range documents {
+		_, err := s.ReplaceDocument(ctx, &store.Document{SourceID: d.sourceID, Path: d.path, Title: d.title, Headings: d.headings}, nil)
+		if err != nil {
+			t.Fatalf("ReplaceDocument(%s) error = %v", d.path, err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		opts store.TopicOptions
+		want string
+	}{
+		{
+			name: "language",
+			opts: store.TopicOptions{LanguageID: goLang.ID},
+			want: "Errors(2)[Wrapping(2)[Sentinels(1)] Panics(1)] Style(1)[Naming(1)]",
+		},
+		{
+			name: "depth",
+			opts: store.TopicOptions{LanguageID: goLang.ID, Depth: 1},
+			want: "Errors(2) Style(1)",
+		},
+		{
+			name: "source",
+			opts: store.TopicOptions{LanguageID: goLang.ID, Sources: []string{"go-wiki"}},
+			want: "Errors(1)[Wrapping(1)[Sentinels(1)] Panics(1)]",
+		},
+		{
+			name: "parent",
+			opts: store.TopicOptions{LanguageID: goLang.ID, Parent: []string{"Errors"}, Depth: 1},
+			want: "Wrapping(2) Panics(1)",
+		},
+		{
+			name: "all languages",
+			opts: store.TopicOptions{Depth: 1},
+			want: "Errors(3) Style(1)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topics, err := s.ListTopics(ctx, tt.opts)
+			if err != nil {
+				t.Fatalf("ListTopics() error = %v", err)
+			}
+			if got := formatTopics(topics); got != tt.want {
+				t.Errorf("ListTopics() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// Re-indexing a document replaces its topics
+	_, err := s.ReplaceDocument(ctx, &store.Document{SourceID: wiki.ID, Path: "errors.md", Title: "Errors"}, nil)
+	if err != nil {
+		t.Fatalf("ReplaceDocument() error = %v", err)
+	}
+	if err := s.DeleteSource(ctx, guide.ID); err != nil {
+		t.Fatalf("DeleteSource() error = %v", err)
+	}
+	topics, err := s.ListTopics(ctx, store.TopicOptions{LanguageID: goLang.ID})
+	if err != nil {
+		t.Fatalf("ListTopics() error = %v", err)
+	}
+	if got, want := formatTopics(topics), "Errors(1)"; got != want {
+		t.Errorf("ListTopics() after re-index = %s, want %s", got, want)
+	}
+}
+
+// formatTopics formats a topic tree compactly, as Name(documents)[children].
+func formatTopics(topics []*store.Topic) string {
+	parts := make([]string, len(topics))
+	for i, topic := range topics {
+		parts[i] = fmt.Sprintf("%s(%d)", topic.Name, topic.Documents)
+		if len(topic.Children) > 0 {
+			parts[i] += "[" + formatTopics(topic.Children) + "]"
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,220 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Vector tables carry copies of each chunk's search metadata (language,
+// source, source tier, level, document path and whether it contains code) as
+// sqlite-vec metadata columns. Constraints on metadata columns are evaluated
+// inside the KNN scan, so a filtered search returns the k nearest matching
+// vectors instead of filtering the global top k afterwards, which starves
+// minority languages and small sources of results.
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// createVectorTable replaces the named vector table with an empty one of the
+// given dimension and the current metadata columns, which must match the
+// layout of the latest migration that rebuilds chunks_vec. name must be a
+// trusted constant.
+func createVectorTable(ctx context.Context, tx *sql.Tx, name string, dims int) error {
+	if _, err := tx.ExecContext(ctx, "DROP TABLE IF EXISTS "+name); err != nil {
+		return fmt.Errorf("drop vector table %s: %w", name, err)
+	}
+
+	_, err := tx.ExecContext(ctx, fmt.Sprintf(`
+		CREATE VIRTUAL TABLE %s USING vec0(
+			chunk_id INTEGER PRIMARY KEY,
+			embedding FLOAT[%d],
+			language_id INTEGER,
+			source_id INTEGER,
+			tier INTEGER,
+			level TEXT,
+			path TEXT,
+			has_code INTEGER
+		)
+	`, name, dims))
+	if err != nil {
+		return fmt.Errorf("create vector table %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// hasCodeExpr reports whether chunk c contains a fenced code block. The
+// parser renders code blocks as Markdown fences.
+const hasCodeExpr = "instr(c.content, '```') > 0"
+
+const (
+	// vectorColumns lists every vector table column in insertion order.
+	vectorColumns = "chunk_id, embedding, language_id, source_id, tier, level, path, has_code"
+	// vectorMetadata selects the metadata columns of vectorColumns for chunk c
+	// joined to its document d and source s.
+	vectorMetadata = "s.language_id, s.id, s.tier, c.level, d.path, " + hasCodeExpr
+	// vectorMetadataSelect selects the values of vectorColumns for one chunk,
+	// taking the embedding and chunk ID as parameters.
+	vectorMetadataSelect = `
+		SELECT c.id, ?, ` + vectorMetadata + `
+		FROM chunks c
+		JOIN documents d ON c.document_id = d.id
+		JOIN sources s ON d.source_id = s.id
+		WHERE c.id = ?`
+)
+
+// insertVector stores an embedding for a chunk in the named vector table,
+// together with the chunk's search metadata. It returns ErrNotFound if the
+// chunk does not exist. table must be a trusted constant.
+func insertVector(ctx context.Context, ex execer, table string, chunkID int64, embedding []float32) error {
+	result, err := ex.ExecContext(ctx,
+		"INSERT INTO "+table+" ("+vectorColumns+")"+vectorMetadataSelect,
+		float32ToBytes(embedding), chunkID,
+	)
+	if err != nil {
+		return fmt.Errorf("insert embedding: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("insert embedding: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("chunk %d: %w", chunkID, ErrNotFound)
+	}
+	return nil
+}
+
+// knnQuery returns a sqlite-vec KNN query selecting chunk_id, distance and
+// embedding of the k vectors nearest to queryVec that match filter.
+func knnQuery(queryVec []float32, filter *searchFilter, k int) (string, []any) {
+	query := "SELECT chunk_id, distance, embedding FROM chunks_vec WHERE embedding MATCH ? AND k = ?"
+	args := []any{float32ToBytes(queryVec), k}
+	conds, condArgs := filter.conditions(vectorFilterColumns)
+	for _, cond := range conds {
+		query += " AND " + cond
+	}
+	return query, append(args, condArgs...)
+}
+
+// copyVectors fills chunks_vec from the chunk_id and embedding columns of
+// table, reading fresh metadata for each chunk. Vectors whose chunk no
+// longer exists are dropped. table must be a trusted constant.
+func copyVectors(ctx context.Context, tx *sql.Tx, table string) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO chunks_vec (`+vectorColumns+`)
+		SELECT o.chunk_id, o.embedding, `+vectorMetadata+`
+		FROM `+table+` o
+		JOIN chunks c ON o.chunk_id = c.id
+		JOIN documents d ON c.document_id = d.id
+		JOIN sources s ON d.source_id = s.id
+	`)
+	if err != nil {
+		return fmt.Errorf("copy vectors: %w", err)
+	}
+	return nil
+}
+
+// vectorLayout is the set of metadata columns of chunks_vec at one schema
+// version. Each migration that changes the layout spells it out in full, so
+// later layouts never change what a shipped migration does.
+type vectorLayout struct {
+	defs    string // Column definitions following the embedding
+	columns string // Names of those columns, in order
+	values  string // Their values for chunk c of document d in source s
+}
+
+// addVectorMetadata is migration 5. It rebuilds chunks_vec with language,
+// source and level metadata columns.
+func addVectorMetadata(ctx context.Context, tx *sql.Tx) error {
+	return rebuildVectorTable(ctx, tx, vectorLayout{
+		defs:    "language_id INTEGER, source_id INTEGER, level TEXT",
+		columns: "language_id, source_id, level",
+		values:  "s.language_id, s.id, c.level",
+	})
+}
+
+// addVectorPathMetadata is migration 6. It adds document path and code
+// metadata columns to chunks_vec.
+func addVectorPathMetadata(ctx context.Context, tx *sql.Tx) error {
+	return rebuildVectorTable(ctx, tx, vectorLayout{
+		defs:    "language_id INTEGER, source_id INTEGER, level TEXT, path TEXT, has_code INTEGER",
+		columns: "language_id, source_id, level, path, has_code",
+		values:  "s.language_id, s.id, c.level, d.path, instr(c.content, '```') > 0",
+	})
+}
+
+// addSourceTiers is migration 7. It adds a tier to sources and a tier
+// metadata column to chunks_vec.
+func addSourceTiers(ctx context.Context, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, "ALTER TABLE sources ADD COLUMN tier INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return fmt.Errorf("add source tier: %w", err)
+	}
+	return rebuildVectorTable(ctx, tx, vectorLayout{
+		defs:    "language_id INTEGER, source_id INTEGER, tier INTEGER, level TEXT, path TEXT, has_code INTEGER",
+		columns: "language_id, source_id, tier, level, path, has_code",
+		values:  "s.language_id, s.id, s.tier, c.level, d.path, instr(c.content, '```') > 0",
+	})
+}
+
+// rebuildVectorTable recreates chunks_vec with the given metadata columns,
+// keeping existing vectors.
+func rebuildVectorTable(ctx context.Context, tx *sql.Tx, layout vectorLayout) error {
+	// Keep the registered model's dimension, even if every vector has been
+	// removed. Without a model the table is empty and is recreated at the
+	// right size when one is registered.
+	dims := 1024
+	var registered string
+	err := tx.QueryRowContext(ctx, "SELECT value FROM metadata WHERE key = 'embedding_dimensions'").Scan(&registered)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return fmt.Errorf("query vector dimensions: %w", err)
+	default:
+		if dims, err = strconv.Atoi(registered); err != nil {
+			return fmt.Errorf("parse embedding dimensions %q: %w", registered, err)
+		}
+	}
+
+	// sqlite-vec tables can be neither altered nor renamed, so park the
+	// vectors in a plain table while chunks_vec is recreated.
+	if _, err := tx.ExecContext(ctx, "CREATE TEMP TABLE chunks_vec_old AS SELECT chunk_id, embedding FROM chunks_vec"); err != nil {
+		return fmt.Errorf("stage vectors: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DROP TABLE chunks_vec"); err != nil {
+		return fmt.Errorf("drop vector table: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(`
+		CREATE VIRTUAL TABLE chunks_vec USING vec0(
+			chunk_id INTEGER PRIMARY KEY,
+			embedding FLOAT[%d],
+			%s
+		)
+	`, dims, layout.defs))
+	if err != nil {
+		return fmt.Errorf("create vector table: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO chunks_vec (chunk_id, embedding, `+layout.columns+`)
+		SELECT o.chunk_id, o.embedding, `+layout.values+`
+		FROM chunks_vec_old o
+		JOIN chunks c ON o.chunk_id = c.id
+		JOIN documents d ON c.document_id = d.id
+		JOIN sources s ON d.source_id = s.id
+	`)
+	if err != nil {
+		return fmt.Errorf("copy vectors: %w", err)
+	}
+
+	// A staged re-embed lacks the new columns; drop it so it restarts cleanly
+	return execStatements(
+		"DROP TABLE chunks_vec_old",
+		"DROP TABLE IF EXISTS chunks_vec_next",
+		"DELETE FROM metadata WHERE key IN ('"+metaReembedModel+"', '"+metaReembedDimensions+"')",
+	)(ctx, tx)
+}
@@ -0,0 +1,210 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jamesainslie/grimoire/internal/store"
+)
+
+func TestStore_SearchChunksVector_LanguageFilter(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	goLang, _ := s.CreateLanguage(ctx, "go", "Go")
+	rustLang, _ := s.CreateLanguage(ctx, "rust", "Rust")
+	goSrc, _ := s.CreateSource(ctx, goLang.ID, "effective-go", "git", "https://github.com/golang/go")
+	rustSrc, _ := s.CreateSource(ctx, rustLang.ID, "rust-book", "git", "https://github.com/rust-lang/book")
+
+	// The majority language sits right next to the query; the minority
+	// language is further away than every one of its vectors.
+	goDocs := seedVectors(t, s, goSrc.ID, "go", 8, 0.5)
+	seedVectors(t, s, rustSrc.ID, "rust", 300, 1.0)
+
+	query := make([]float32, 1024)
+	query[0] = 1.0
+
+	const limit = 5
+	tests := []struct {
+		name       string
+		languageID int64
+		wantGo     bool
+	}{
+		{name: "all languages", languageID: 0, wantGo: false},
+		{name: "minority language", languageID: goLang.ID, wantGo: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := s.SearchChunksVectorWithScore(ctx, query, store.SearchOptions{LanguageID: tt.languageID}, limit)
+			if err != nil {
+				t.Fatalf("SearchChunksVectorWithScore() error = %v", err)
+			}
+			if len(results) != limit {
+				t.Fatalf("SearchChunksVectorWithScore() = %d results, want %d", len(results), limit)
+			}
+			for _, r := range results {
+				if goDocs[r.Chunk.DocumentID] != tt.wantGo {
+					t.Errorf("result from document %d, want Go = %v", r.Chunk.DocumentID, tt.wantGo)
+				}
+			}
+
+			chunks, err := s.SearchChunksVector(ctx, query, store.SearchOptions{LanguageID: tt.languageID}, limit)
+			if err != nil {
+				t.Fatalf("SearchChunksVector() error = %v", err)
+			}
+			if len(chunks) != limit {
+				t.Errorf("SearchChunksVector() = %d results, want %d", len(chunks), limit)
+			}
+
+			hybrid, err := s.SearchChunksHybrid(ctx, query, "ownership", store.SearchOptions{LanguageID: tt.languageID}, limit)
+			if err != nil {
+				t.Fatalf("SearchChunksHybrid() error = %v", err)
+			}
+			if len(hybrid) != limit {
+				t.Errorf("SearchChunksHybrid() = %d results, want %d", len(hybrid), limit)
+			}
+		})
+	}
+}
+
+// seedVectors stores n single-chunk documents for a source whose embeddings
+// have the given first component, and returns the IDs of the documents.
+func seedVectors(t *testing.T, s *store.Store, sourceID int64, prefix string, n int, first float32) map[int64]bool {
+	t.Helper()
+
+	docs := make(map[int64]bool, n)
+	for i := range n {
+		embedding := make([]float32, 1024)
+		embedding[0] = first
+		embedding[1+i%1000] = 0.01
+
+		path := fmt.Sprintf("%s/%d.md", prefix, i)
+		doc, err := s.ReplaceDocument(context.Background(), &store.Document{SourceID: sourceID, Path: path, Title: path}, []store.NewChunk{{
+			Level:     "section",
+			Title:     "Ownership",
+			Content:   strings.Repeat("Ownership and borrowing rules explained. ", 4),
+			Embedding: embedding,
+		}})
+		if err != nil {
+			t.Fatalf("ReplaceDocument(%s) error = %v", path, err)
+		}
+		docs[doc.ID] = true
+	}
+	return docs
+}
+
+func TestStore_Search_Filters(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	goLang, _ := s.CreateLanguage(ctx, "go", "Go")
+	effective, _ := s.CreateSource(ctx, goLang.ID, "effective-go", "git", "https://github.com/golang/go")
+	tests, _ := s.CreateSource(ctx, goLang.ID, "learn-go-with-tests", "git", "https://github.com/quii/learn-go-with-tests")
+
+	prose := strings.Repeat("Goroutines are cheap and channels connect them. ", 3)
+	code := prose + "\n```go\ngo worker(jobs)\n```\n"
+	paths := make(map[int64]string)
+	for _, d := range []struct {
+		sourceID    int64
+		path, level string
+		content     string
+	}{
+		{sourceID: effective.ID, path: "docs/concurrency.md", level: "section", content: code},
+		{sourceID: effective.ID, path: "docs/errors.md", level: "summary", content: prose},
+		{sourceID: tests.ID, path: "arrays.md", level: "section", content: code},
+	} {
+		embedding := make([]float32, 1024)
+		embedding[0] = 1
+		doc, err := s.ReplaceDocument(ctx, &store.Document{SourceID: d.sourceID, Path: d.path, Title: d.path}, []store.NewChunk{{
+			Level:     d.level,
+			Title:     "Goroutines",
+			Content:   d.content,
+			Embedding: embedding,
+		}})
+		if err != nil {
+			t.Fatalf("ReplaceDocument(%s) error = %v", d.path, err)
+		}
+		paths[doc.ID] = d.path
+	}
+
+	query := make([]float32, 1024)
+	query[0] = 1
+
+	cases := []struct {
+		name string
+		opts store.SearchOptions
+		want []string
+	}{
+		{name: "no filter", want: []string{"arrays.md", "docs/concurrency.md", "docs/errors.md"}},
+		{name: "source", opts: store.SearchOptions{Sources: []string{"effective-go"}}, want: []string{"docs/concurrency.md", "docs/errors.md"}},
+		{name: "exclude source", opts: store.SearchOptions{ExcludeSources: []string{"learn-go-with-tests"}}, want: []string{"docs/concurrency.md", "docs/errors.md"}},
+		{name: "tier", opts: store.SearchOptions{Tiers: []int{1}}, want: nil},
+		{name: "level", opts: store.SearchOptions{Levels: []string{"summary"}}, want: []string{"docs/errors.md"}},
+		{name: "path prefix", opts: store.SearchOptions{PathPrefix: "docs/c"}, want: []string{"docs/concurrency.md"}},
+		{name: "code only", opts: store.SearchOptions{CodeOnly: true}, want: []string{"arrays.md", "docs/concurrency.md"}},
+		{
+			name: "combined",
+			opts: store.SearchOptions{LanguageID: goLang.ID, Sources: []string{"effective-go"}, CodeOnly: true},
+			want: []string{"docs/concurrency.md"},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			fts, err := s.SearchChunksFTS(ctx, "goroutines", tt.opts, 10)
+			if err != nil {
+				t.Fatalf("SearchChunksFTS() error = %v", err)
+			}
+			var ftsPaths []string
+			for _, c := range fts {
+				ftsPaths = append(ftsPaths, paths[c.DocumentID])
+			}
+			if got := sortedPaths(ftsPaths); !slices.Equal(got, tt.want) {
+				t.Errorf("SearchChunksFTS() paths = %v, want %v", got, tt.want)
+			}
+
+			vec, err := s.SearchChunksVector(ctx, query, tt.opts, 10)
+			if err != nil {
+				t.Fatalf("SearchChunksVector() error = %v", err)
+			}
+			var vecPaths []string
+			for _, c := range vec {
+				vecPaths = append(vecPaths, paths[c.DocumentID])
+			}
+			if got := sortedPaths(vecPaths); !slices.Equal(got, tt.want) {
+				t.Errorf("SearchChunksVector() paths = %v, want %v", got, tt.want)
+			}
+
+			hybrid, err := s.SearchChunksHybrid(ctx, query, "goroutines", tt.opts, 10)
+			if err != nil {
+				t.Fatalf("SearchChunksHybrid() error = %v", err)
+			}
+			if len(hybrid) != len(tt.want) {
+				t.Errorf("SearchChunksHybrid() = %d results, want %d", len(hybrid), len(tt.want))
+			}
+		})
+	}
+
+	_, err := s.SearchChunksFTS(ctx, "goroutines", store.SearchOptions{Sources: []string{"no-such-source"}}, 10)
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("SearchChunksFTS(unknown source) error = %v, want ErrNotFound", err)
+	}
+}
+
+// sortedPaths returns paths sorted, or nil if empty.
+func sortedPaths(paths []string) []string {
+	if len(paths) == 0 {
+		return nil
+	}
+	slices.Sort(paths)
+	return paths
+}